@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Event is something that happened to a secret, like unlocking or re-encrypting it.
+type Event string
+
+const (
+	// UnlockAttempted is recorded before a password is checked.
+	UnlockAttempted Event = "unlock-attempted"
+	// UnlockSucceeded is recorded when the password was correct.
+	UnlockSucceeded Event = "unlock-succeeded"
+	// UnlockFailed is recorded when the password was wrong.
+	UnlockFailed Event = "unlock-failed"
+	// UnlockThrottled is recorded when an attempt was refused because of too many failed attempts.
+	UnlockThrottled Event = "unlock-throttled"
+	// SecretRekeyed is recorded when a secret was re-encrypted with a new password by "secrets rekey".
+	SecretRekeyed Event = "secret-rekeyed"
+	// SecretRekeyFailed is recorded when a secret could not be re-encrypted by "secrets rekey".
+	SecretRekeyFailed Event = "secret-rekey-failed"
+	// SecretMigrated is recorded when a secret was re-encrypted in the current format by "secrets migrate".
+	SecretMigrated Event = "secret-migrated"
+	// SecretMigrateFailed is recorded when a secret could not be re-encrypted by "secrets migrate".
+	SecretMigrateFailed Event = "secret-migrate-failed"
+)
+
+// Log appends events to the audit log file, one line per event with the time and the label of the secret,
+// e.g. a snippet or the master key. Secrets and passwords are never written to it.
+type Log struct {
+	file string
+	lock sync.Mutex
+	now  func() time.Time
+}
+
+// New creates a Log that appends to the file. The file and its directory are created when the first event is recorded.
+func New(file string) *Log {
+	return &Log{file: file, now: time.Now}
+}
+
+// Record appends the event to the audit log.
+func (l *Log) Record(event Event, label string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	err := os.MkdirAll(filepath.Dir(l.file), 0700)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "%s %s %q\n", l.now().Format(time.RFC3339), event, label)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
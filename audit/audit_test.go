@@ -0,0 +1,30 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecord(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "state", "audit.log")
+	l := New(file)
+	l.now = func() time.Time {
+		return time.Date(2021, 11, 20, 14, 3, 12, 0, time.UTC)
+	}
+
+	assert.Nil(t, l.Record(UnlockAttempted, "root password"))
+	assert.Nil(t, l.Record(UnlockFailed, "root password"))
+
+	content, err := os.ReadFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, "2021-11-20T14:03:12Z unlock-attempted \"root password\"\n"+
+		"2021-11-20T14:03:12Z unlock-failed \"root password\"\n", string(content))
+
+	info, err := os.Stat(file)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sandro-h/snippet/secrets"
+	"github.com/sandro-h/snippet/util"
+)
+
+// command is a subcommand of the snippet CLI, e.g. "snippet tui".
+type command struct {
+	name        string
+	args        string
+	description string
+	run         func(args []string) error
+	// complete returns the shell completion candidates for the last of the args.
+	complete func(args []string) []string
+	hidden   bool
+}
+
+// prompter asks the user for input when a snippet is used outside of the snippet widget.
+type prompter interface {
+	// Prompt asks for a value for the label. The second return value is false if the user cancelled.
+	Prompt(label string, masked bool) (string, bool)
+}
+
+var errCancelled = errors.New("cancelled")
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "tui", args: "[query]", description: "Pick a snippet in the terminal and print it to stdout", run: runTUI, complete: completeLabels},
+		{name: "menu", args: "--format F [--select]", description: "List snippets for rofi, dmenu or fzf, or type the chosen line", run: runMenu, complete: completeMenu},
+		{name: "labels", description: "Print the labels of all snippets", run: runLabels},
+		{name: "undo", description: "Remove the last typed snippet from the focused window", run: runUndo},
+		{name: "secrets", args: "<command>", description: "Manage secrets and the master key, see \"secrets\" for the commands", run: runSecrets, complete: completeSecrets},
+		{name: "profile", args: "[name]", description: "Print the profiles, or switch to the given profile", run: runProfile, complete: completeProfiles},
+		{name: "completion", args: "bash|zsh|fish", description: "Print the shell completion script", run: runCompletion, complete: completeShells},
+		{name: "shell-init", args: "bash|zsh|fish", description: "Print the shell completion and Ctrl+X S picker keybinding script", run: runShellInit, complete: completeShells},
+		{name: "__complete", run: runComplete, hidden: true},
+	}
+}
+
+func runCommand(name string, args []string) {
+	for _, c := range commands {
+		if c.name == name {
+			err := c.run(args)
+			if err == errCancelled {
+				os.Exit(1)
+			} else if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command %s\n", name)
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [command]\n\n", filepath.Base(os.Args[0]))
+	fmt.Fprintf(out, "Without a command, the snippet widget is started.\n\nCommands:\n")
+	for _, c := range commands {
+		if c.hidden {
+			continue
+		}
+		fmt.Fprintf(out, "  %-30s %s\n", c.name+" "+c.args, c.description)
+	}
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// loadSnippets loads the config and the snippets of the active profile for a command.
+// Problems with individual snippets are printed to stderr.
+func loadSnippets() ([]*util.Snippet, *config, error) {
+	c, err := loadActiveConfig(profileOverride())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	snippets, snippetErrs, err := util.LoadSnippetFiles(snippetsFilesFor(c))
+	for _, e := range snippetErrs {
+		fmt.Fprintln(os.Stderr, e)
+	}
+	return snippets, c, err
+}
+
+// renderSnippet returns the content to insert for the snippet. It decrypts secrets and resolves
+// arguments, prompting the user for passwords and manual arguments that are not set by the profile variables.
+// The caller should wipe the content after use, since it may be a secret.
+func renderSnippet(snippet *util.Snippet, c *config, p prompter) ([]byte, error) {
+	if snippet.IsSecret() {
+		decrypted, err := decryptSecret(snippet, c, p)
+		if err != nil || snippet.TOTP == nil {
+			return decrypted, err
+		}
+		return totpCode(snippet, decrypted)
+	}
+
+	vals, manualArgs, err := util.ResolveArgs(snippet, c.variables)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range manualArgs {
+		val, ok := p.Prompt(a, false)
+		if !ok {
+			return nil, errCancelled
+		}
+		vals[a] = val
+	}
+	return []byte(util.InstantiateArgs(snippet.Content, vals)), nil
+}
+
+// decryptSecret decrypts a secret snippet, prompting the user for the password or passphrase.
+// For TOTP snippets, it returns the seed. Unlocks with a password are throttled and recorded in the audit log
+// like in the password window, see tryUnlock.
+func decryptSecret(snippet *util.Snippet, c *config, p prompter) ([]byte, error) {
+	if snippet.SecretAge != "" {
+		identityFile, err := readAgeIdentityFile(c)
+		if err != nil {
+			return nil, err
+		}
+		if !secrets.IsAgeIdentityEncrypted(identityFile) {
+			return decryptAgeSecret(snippet.SecretAge, identityFile, "")
+		}
+		passphrase, ok := p.Prompt("Passphrase for age identity", true)
+		if !ok {
+			return nil, errCancelled
+		}
+		var decrypted []byte
+		err = tryUnlock("age identity", snippet.Label, func() error {
+			var err error
+			decrypted, err = decryptAgeSecret(snippet.SecretAge, identityFile, passphrase)
+			return err
+		})
+		return decrypted, err
+	}
+
+	if secrets.IsMasterSecret(snippet.Secret) {
+		master, err := loadMasterKey()
+		if err != nil {
+			return nil, err
+		}
+		pwd, ok := p.Prompt("Master password", true)
+		if !ok {
+			return nil, errCancelled
+		}
+		var key []byte
+		err = tryUnlock("master key", snippet.Label, func() error {
+			var err error
+			key, err = master.Unlock(pwd)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		defer secrets.Wipe(key)
+		return secrets.DecryptWithMasterKey(snippet.Secret, key)
+	}
+
+	pwd, ok := p.Prompt("Password for secret "+snippet.Label, true)
+	if !ok {
+		return nil, errCancelled
+	}
+	var decrypted []byte
+	err := tryUnlock("secret:"+snippet.Label, snippet.Label, func() error {
+		var err error
+		decrypted, err = secrets.Decrypt(snippet.Secret, pwd)
+		return err
+	})
+	return decrypted, err
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sandro-h/snippet/menu"
+	"github.com/sandro-h/snippet/shell"
+)
+
+func runLabels(args []string) error {
+	snippets, _, err := loadSnippets()
+	if err != nil {
+		return err
+	}
+	for _, s := range snippets {
+		fmt.Println(s.Label)
+	}
+	return nil
+}
+
+func runCompletion(args []string) error {
+	return printShellScript(args, shell.Completion)
+}
+
+func runShellInit(args []string) error {
+	return printShellScript(args, shell.Init)
+}
+
+func printShellScript(args []string, script func(shell string, name string, bin string) (string, error)) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected shell argument, one of: %s", strings.Join(shell.Shells, ", "))
+	}
+
+	bin, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	str, err := script(args[0], filepath.Base(os.Args[0]), bin)
+	if err != nil {
+		return err
+	}
+	fmt.Print(str)
+	return nil
+}
+
+// runComplete prints the completion candidates for the last of the args, one per line.
+// The args are the command line words after the executable, up to and including the word being completed.
+func runComplete(args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	var candidates []string
+	if len(args) == 1 {
+		candidates = completeCommands(args[0])
+	} else {
+		for _, c := range commands {
+			if c.name == args[0] && c.complete != nil {
+				candidates = c.complete(args[1:])
+			}
+		}
+	}
+
+	cur := args[len(args)-1]
+	for _, c := range candidates {
+		if strings.HasPrefix(c, cur) {
+			fmt.Println(c)
+		}
+	}
+	return nil
+}
+
+func completeCommands(cur string) []string {
+	var candidates []string
+	if strings.HasPrefix(cur, "-") {
+		flag.VisitAll(func(f *flag.Flag) {
+			candidates = append(candidates, "--"+f.Name)
+		})
+		return candidates
+	}
+
+	for _, c := range commands {
+		if !c.hidden {
+			candidates = append(candidates, c.name)
+		}
+	}
+	return candidates
+}
+
+func completeLabels(args []string) []string {
+	snippets, _, err := loadSnippets()
+	if err != nil {
+		return nil
+	}
+
+	var labels []string
+	for _, s := range snippets {
+		labels = append(labels, s.Label)
+	}
+	return labels
+}
+
+func completeMenu(args []string) []string {
+	if len(args) > 1 && strings.TrimLeft(args[len(args)-2], "-") == "format" {
+		return menu.Formats()
+	}
+	if strings.HasPrefix(args[len(args)-1], "-") {
+		return []string{"--format", "--select"}
+	}
+	return completeLabels(args)
+}
+
+func completeShells(args []string) []string {
+	if len(args) > 1 {
+		return nil
+	}
+	return shell.Shells
+}
@@ -33,3 +33,36 @@ func TestSearchFuzzy(t *testing.T) {
 		assert.Equal(t, c.expected, actual)
 	}
 }
+
+func TestHighlightSegments(t *testing.T) {
+	cases := []struct {
+		text     string
+		match    Match
+		expected []Segment
+	}{
+		{
+			"docker bash",
+			Match{Index: -1},
+			[]Segment{{"docker bash", false}},
+		},
+		{
+			"docker bash",
+			Match{Index: 0, MatchedRanges: []MatchRange{{0, 1}, {7, 8}}},
+			[]Segment{{"do", true}, {"cker ", false}, {"ba", true}, {"sh", false}},
+		},
+		{
+			"bash",
+			Match{Index: 0, MatchedRanges: []MatchRange{{2, 3}}},
+			[]Segment{{"ba", false}, {"sh", true}},
+		},
+		{
+			"",
+			Match{Index: 0},
+			[]Segment{},
+		},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, HighlightSegments(c.text, c.match))
+	}
+}
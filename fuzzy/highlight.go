@@ -0,0 +1,32 @@
+package fuzzy
+
+// Segment is a part of a matched string, either highlighted because it was matched or not.
+type Segment struct {
+	Str         string
+	Highlighted bool
+}
+
+// HighlightSegments splits the text into segments according to the matched ranges of the match.
+// If the match is not a match (index -1), the whole text is returned as a single non-highlighted segment.
+func HighlightSegments(text string, match Match) []Segment {
+	segments := []Segment{{Str: text, Highlighted: false}}
+	if match.Index > -1 {
+		segments = make([]Segment, 0)
+
+		li := 0
+		for _, r := range match.MatchedRanges {
+			if r.Start >= len(text) {
+				break
+			}
+			if r.Start > li {
+				segments = append(segments, Segment{Str: text[li:r.Start], Highlighted: false})
+			}
+			segments = append(segments, Segment{Str: text[r.Start : r.End+1], Highlighted: true})
+			li = r.End + 1
+		}
+		if li < len(text) {
+			segments = append(segments, Segment{Str: text[li:], Highlighted: false})
+		}
+	}
+	return segments
+}
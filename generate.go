@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/sandro-h/snippet/passgen"
+	"github.com/sandro-h/snippet/secrets"
+	"github.com/sandro-h/snippet/ui"
+	"github.com/sandro-h/snippet/util"
+)
+
+// generateFlags choose how "secrets generate" generates a password.
+type generateFlags struct {
+	length           *int
+	noLower          *bool
+	noUpper          *bool
+	noDigits         *bool
+	noSymbols        *bool
+	excludeAmbiguous *bool
+	words            *int
+	separator        *string
+	wordList         *string
+}
+
+func newGenerateFlags(fs *flag.FlagSet) *generateFlags {
+	return &generateFlags{
+		length:           fs.Int("length", passgen.DefaultOptions.Length, "Length of the password"),
+		noLower:          fs.Bool("no-lower", false, "Do not use lowercase letters"),
+		noUpper:          fs.Bool("no-upper", false, "Do not use uppercase letters"),
+		noDigits:         fs.Bool("no-digits", false, "Do not use digits"),
+		noSymbols:        fs.Bool("no-symbols", false, "Do not use symbols"),
+		excludeAmbiguous: fs.Bool("exclude-ambiguous", false, "Do not use characters that look alike, like l, 1 and I"),
+		words:            fs.Int("words", 0, "Generate a passphrase of this many words instead of a password"),
+		separator:        fs.String("separator", passgen.DefaultPassphraseOptions.Separator, "Separator between the words of a passphrase"),
+		wordList:         fs.String("wordlist", "", "File with the words for a passphrase, one per line. Default: a built-in list of 2048 english words"),
+	}
+}
+
+// generate generates the password or passphrase. The caller should wipe it after use with secrets.Wipe.
+func (f *generateFlags) generate() ([]byte, error) {
+	if *f.words == 0 {
+		return passgen.Password(passgen.Options{
+			Length:           *f.length,
+			Lower:            !*f.noLower,
+			Upper:            !*f.noUpper,
+			Digits:           !*f.noDigits,
+			Symbols:          !*f.noSymbols,
+			ExcludeAmbiguous: *f.excludeAmbiguous,
+		})
+	}
+
+	opts := passgen.PassphraseOptions{Words: *f.words, Separator: *f.separator}
+	if *f.wordList != "" {
+		// ~/ is expanded here too, since the shell does not do that for /generate in the search widget.
+		file, err := util.ResolvePath(*f.wordList, ".")
+		if err != nil {
+			return nil, err
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		opts.WordList = passgen.ParseWordList(content)
+	}
+	return passgen.Passphrase(opts)
+}
+
+// runGenerate generates a password, adds it encrypted as a new secret snippet and prints it to stdout,
+// so it can be used right away, e.g. for a new service account.
+func runGenerate(args []string) error {
+	fs := flag.NewFlagSet("secrets generate", flag.ExitOnError)
+	ef := newEncryptFlags(fs)
+	gf := newGenerateFlags(fs)
+	file := fs.String("file", "", "Snippets file to add the secret to. Default: the first snippets file")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("generate takes the label of the new snippet")
+	}
+	label := fs.Arg(0)
+
+	c, err := loadActiveConfig(profileOverride())
+	if err != nil {
+		return err
+	}
+	if *file == "" {
+		*file, err = defaultSnippetsFile(c)
+		if err != nil {
+			return err
+		}
+	}
+	pwd, err := gf.generate()
+	if err != nil {
+		return err
+	}
+	defer secrets.Wipe(pwd)
+
+	enc, field, err := ef.encrypt(c, pwd)
+	if err != nil {
+		return err
+	}
+	err = util.AddSecret(*file, label, field, enc)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Added %s to %s\n", label, *file)
+	_, err = os.Stdout.Write(pwd)
+	fmt.Println()
+	return err
+}
+
+// generateSecret generates a password or passphrase as chosen by gf, adds it as a new secret snippet to the first
+// snippets file and types it once, e.g. into the sign-up form of a new account. It is encrypted with the master key
+// if there is one, otherwise with a password that is asked for twice.
+func generateSecret(state *appState, label string, gf *generateFlags) {
+	state.mainWindow.Hide()
+	showError := func(err error) {
+		log.Printf("Could not generate secret %s: %s", label, err)
+		state.errorBanner.SetErrors("secrets", []string{fmt.Sprintf("Could not generate secret %s: %s", label, err)})
+		state.mainWindow.Show()
+	}
+
+	if state.store.Find(label) != nil {
+		showError(fmt.Errorf("snippet %s already exists", label))
+		return
+	}
+	snippetsFiles := currentSnippetsFiles(state)
+	if len(snippetsFiles) == 0 {
+		showError(errors.New("there is no snippets file to add it to"))
+		return
+	}
+	pwd, err := gf.generate()
+	if err != nil {
+		showError(err)
+		return
+	}
+
+	// addAndType hands pwd over to outputSnippet, which wipes it once it is typed. Every other path wipes it here.
+	addAndType := func(enc string, err error) {
+		if err == nil {
+			err = util.AddSecret(snippetsFiles[0], label, "secret", enc)
+		}
+		if err != nil {
+			secrets.Wipe(pwd)
+			showError(err)
+			return
+		}
+		state.errorBanner.SetErrors("secrets", nil)
+		log.Printf("Added generated secret %s to %s", label, snippetsFiles[0])
+		outputSnippet(state, pwd, &util.Snippet{Label: label}, false)
+	}
+	onCancel := func() {
+		secrets.Wipe(pwd)
+		state.mainWindow.Show()
+	}
+
+	if _, err := os.Stat(masterKeyFile()); err == nil {
+		encryptWithKey := func(key []byte) {
+			defer secrets.Wipe(key)
+			addAndType(secrets.EncryptWithMasterKey(pwd, key))
+		}
+		if key, ok := state.store.UnlockedMasterKey(); ok {
+			encryptWithKey(key)
+			return
+		}
+		master, err := loadMasterKey()
+		if err != nil {
+			secrets.Wipe(pwd)
+			showError(err)
+			return
+		}
+		var key []byte
+		ui.ShowPasswordWindow(state.passwordWindow, "Master password",
+			func(masterPwd string) error {
+				return tryUnlock("master key", label, func() error {
+					var err error
+					key, err = master.Unlock(masterPwd)
+					return err
+				})
+			},
+			func() {
+				state.store.UnlockMasterKey(key)
+				encryptWithKey(key)
+			},
+			onCancel,
+		)
+		return
+	}
+
+	var secretPwd []byte
+	onPasswordCancel := func() {
+		secrets.Wipe(secretPwd)
+		onCancel()
+	}
+	ui.ShowPasswordWindow(state.passwordWindow, "Password for new secret "+label,
+		func(p string) error {
+			secretPwd = []byte(p)
+			return nil
+		},
+		func() {
+			ui.ShowPasswordWindow(state.passwordWindow, "Repeat password for new secret "+label,
+				func(repeated string) error {
+					if string(secretPwd) != repeated {
+						return errors.New("the passwords do not match")
+					}
+					return nil
+				},
+				func() {
+					enc, err := secrets.EncryptWithParams(pwd, secretPwd, currentConfig().kdf)
+					secrets.Wipe(secretPwd)
+					addAndType(enc, err)
+				},
+				onPasswordCancel,
+			)
+		},
+		onPasswordCancel,
+	)
+}
+
+// runGenerateCommand runs "/generate [options] <label>" from the search widget. The options are the ones of
+// "secrets generate" that choose the password, e.g. --length 30 or --words 5. The label can contain spaces.
+func runGenerateCommand(state *appState, args []string) {
+	const usage = "Usage: /generate [--length N] [--no-lower] [--no-upper] [--no-digits] [--no-symbols] " +
+		"[--exclude-ambiguous] [--words N] [--separator S] [--wordlist FILE] <label>"
+	fs := flag.NewFlagSet("/generate", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	gf := newGenerateFlags(fs)
+	err := fs.Parse(args)
+	if err != nil {
+		state.errorBanner.SetErrors("secrets", []string{fmt.Sprintf("Invalid /generate options: %s", err), usage})
+		return
+	}
+	if fs.NArg() == 0 {
+		state.errorBanner.SetErrors("secrets", []string{usage})
+		return
+	}
+	generateSecret(state, strings.Join(fs.Args(), " "), gf)
+}
@@ -3,14 +3,20 @@ module github.com/sandro-h/snippet
 go 1.16
 
 require (
+	filippo.io/age v1.0.0
 	fyne.io/fyne/v2 v2.1.0
 	github.com/fsnotify/fsnotify v1.4.9
+	github.com/gdamore/tcell/v2 v2.4.0
 	github.com/go-vgo/robotgo v0.93.1
+	github.com/godbus/dbus/v5 v5.0.4
+	github.com/mattn/go-runewidth v0.0.10
 	github.com/robotn/gohook v0.30.6
 	github.com/sosedoff/ansible-vault-go v0.0.0-20201201002713-782dc5c40224
 	github.com/stretchr/testify v1.5.1
-	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
+	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
+	golang.org/x/sys v0.7.0
 	gopkg.in/yaml.v2 v2.2.8
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 replace github.com/go-vgo/robotgo => github.com/sandro-h/robotgo v0.99.0-linuxfix3
@@ -0,0 +1,161 @@
+package hotkey
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PressInterval is the maximum time between two presses of a hotkey that has to be pressed several times.
+const PressInterval = 500 * time.Millisecond
+
+// Binding binds an action to a key combination.
+type Binding struct {
+	Keys []string
+	// Presses is how many times the key combination has to be pressed in a row, e.g. 2 for a double press.
+	// Zero means once.
+	Presses int
+	Action  func()
+}
+
+type resolvedBinding struct {
+	keycodes []uint16
+	presses  int
+	action   func()
+	// count is the number of presses in a row so far, lastPress the time of the last one.
+	count     int
+	lastPress time.Time
+}
+
+// Dispatcher runs the actions of the hotkeys whose keys are all pressed.
+// Unlike hooks registered directly with robotgo, its bindings can be replaced at any time,
+// e.g. when config.yml changes.
+type Dispatcher struct {
+	keycodes map[string]uint16
+	lock     sync.Mutex
+	pressed  map[uint16]bool
+	bindings []*resolvedBinding
+	now      func() time.Time
+	// lastActivity is the time of the last key press, to detect when the user is idle.
+	lastActivity time.Time
+}
+
+// NewDispatcher creates a Dispatcher without bindings. keycodes maps the key names used in
+// bindings to the keycodes of the key events, e.g. gohook's hook.Keycode.
+func NewDispatcher(keycodes map[string]uint16) *Dispatcher {
+	return &Dispatcher{
+		keycodes:     keycodes,
+		pressed:      make(map[uint16]bool),
+		now:          time.Now,
+		lastActivity: time.Now(),
+	}
+}
+
+// SetBindings replaces all bindings. If any binding is invalid, an error is returned
+// and the previous bindings are kept.
+func (d *Dispatcher) SetBindings(bindings []Binding) error {
+	var resolved []*resolvedBinding
+	for _, b := range bindings {
+		if len(b.Keys) == 0 {
+			return fmt.Errorf("hotkey must have at least one key")
+		}
+		r := &resolvedBinding{action: b.Action, presses: b.Presses}
+		for _, k := range b.Keys {
+			code, ok := d.keycodes[k]
+			if !ok {
+				return fmt.Errorf("unknown key '%s' in hotkey [%s]", k, strings.Join(b.Keys, ", "))
+			}
+			r.keycodes = append(r.keycodes, code)
+		}
+		resolved = append(resolved, r)
+	}
+
+	d.lock.Lock()
+	d.bindings = resolved
+	d.lock.Unlock()
+	return nil
+}
+
+// Press handles a key press and runs the actions of all hotkeys that are now fully pressed
+// the required number of times. The actions run on the calling goroutine.
+func (d *Dispatcher) Press(keycode uint16) {
+	d.lock.Lock()
+	// Holding a key down repeats the press event, which must not count as pressing it several times.
+	repeated := d.pressed[keycode]
+	d.pressed[keycode] = true
+	now := d.now()
+	d.lastActivity = now
+	var actions []func()
+	for _, b := range d.bindings {
+		if !d.allPressed(b.keycodes) {
+			b.count = 0
+			continue
+		}
+		if b.presses <= 1 {
+			actions = append(actions, b.action)
+			continue
+		}
+		if repeated {
+			continue
+		}
+
+		if b.count > 0 && now.Sub(b.lastPress) <= PressInterval {
+			b.count++
+		} else {
+			b.count = 1
+		}
+		b.lastPress = now
+		if b.count >= b.presses {
+			b.count = 0
+			actions = append(actions, b.action)
+		}
+	}
+	d.lock.Unlock()
+
+	for _, a := range actions {
+		a()
+	}
+}
+
+// WaitUntilReleased blocks until no keys are pressed, or the timeout expires. Actions that simulate
+// key presses should call it first on a new goroutine, because the held keys of the hotkey would
+// modify the simulated key presses, and the key release events are handled on the action's goroutine.
+func (d *Dispatcher) WaitUntilReleased(timeout time.Duration) bool {
+	deadline := d.now().Add(timeout)
+	for {
+		d.lock.Lock()
+		released := len(d.pressed) == 0
+		d.lock.Unlock()
+		if released {
+			return true
+		}
+		if d.now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// LastActivity returns the time of the last key press, or when the Dispatcher was created if no key was pressed yet.
+func (d *Dispatcher) LastActivity() time.Time {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.lastActivity
+}
+
+// Release handles a key release.
+func (d *Dispatcher) Release(keycode uint16) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.pressed, keycode)
+}
+
+func (d *Dispatcher) allPressed(keycodes []uint16) bool {
+	for _, k := range keycodes {
+		if !d.pressed[k] {
+			return false
+		}
+	}
+	return true
+}
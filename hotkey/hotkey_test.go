@@ -0,0 +1,151 @@
+package hotkey
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testKeycodes = map[string]uint16{
+	"q":   16,
+	"e":   18,
+	"alt": 56,
+}
+
+func TestPressRunsBindingWhenAllKeysPressed(t *testing.T) {
+	d := NewDispatcher(testKeycodes)
+	count := 0
+	err := d.SetBindings([]Binding{{Keys: []string{"q", "alt"}, Action: func() { count++ }}})
+	assert.Nil(t, err)
+
+	d.Press(56)
+	assert.Equal(t, 0, count)
+	d.Press(16)
+	assert.Equal(t, 1, count)
+
+	d.Release(16)
+	d.Press(18)
+	assert.Equal(t, 1, count)
+}
+
+func TestReleaseStopsBinding(t *testing.T) {
+	d := NewDispatcher(testKeycodes)
+	count := 0
+	d.SetBindings([]Binding{{Keys: []string{"q", "alt"}, Action: func() { count++ }}})
+
+	d.Press(56)
+	d.Release(56)
+	d.Press(16)
+	assert.Equal(t, 0, count)
+}
+
+func TestSetBindingsReplacesBindings(t *testing.T) {
+	d := NewDispatcher(testKeycodes)
+	var fired []string
+	d.SetBindings([]Binding{{Keys: []string{"q", "alt"}, Action: func() { fired = append(fired, "old") }}})
+	d.SetBindings([]Binding{{Keys: []string{"e", "alt"}, Action: func() { fired = append(fired, "new") }}})
+
+	d.Press(56)
+	d.Press(16)
+	d.Release(16)
+	d.Press(18)
+	assert.Equal(t, []string{"new"}, fired)
+}
+
+func TestSetBindingsRejectsUnknownKey(t *testing.T) {
+	d := NewDispatcher(testKeycodes)
+	count := 0
+	d.SetBindings([]Binding{{Keys: []string{"q", "alt"}, Action: func() { count++ }}})
+
+	err := d.SetBindings([]Binding{{Keys: []string{"x", "alt"}, Action: func() {}}})
+	assert.EqualError(t, err, "unknown key 'x' in hotkey [x, alt]")
+
+	d.Press(56)
+	d.Press(16)
+	assert.Equal(t, 1, count)
+}
+
+func TestSetBindingsRejectsEmptyHotkey(t *testing.T) {
+	d := NewDispatcher(testKeycodes)
+	err := d.SetBindings([]Binding{{Keys: []string{}, Action: func() {}}})
+	assert.EqualError(t, err, "hotkey must have at least one key")
+}
+
+func TestDoublePress(t *testing.T) {
+	d := NewDispatcher(testKeycodes)
+	now := time.Now()
+	d.now = func() time.Time { return now }
+	count := 0
+	d.SetBindings([]Binding{{Keys: []string{"q"}, Presses: 2, Action: func() { count++ }}})
+
+	d.Press(16)
+	assert.Equal(t, 0, count)
+	d.Release(16)
+	now = now.Add(100 * time.Millisecond)
+	d.Press(16)
+	assert.Equal(t, 1, count)
+	d.Release(16)
+
+	// Needs two new presses after firing.
+	now = now.Add(100 * time.Millisecond)
+	d.Press(16)
+	assert.Equal(t, 1, count)
+}
+
+func TestDoublePressTooSlow(t *testing.T) {
+	d := NewDispatcher(testKeycodes)
+	now := time.Now()
+	d.now = func() time.Time { return now }
+	count := 0
+	d.SetBindings([]Binding{{Keys: []string{"q"}, Presses: 2, Action: func() { count++ }}})
+
+	d.Press(16)
+	d.Release(16)
+	now = now.Add(PressInterval + time.Millisecond)
+	d.Press(16)
+	assert.Equal(t, 0, count)
+}
+
+func TestDoublePressIgnoresHeldKeyAndOtherKeys(t *testing.T) {
+	d := NewDispatcher(testKeycodes)
+	count := 0
+	d.SetBindings([]Binding{{Keys: []string{"q"}, Presses: 2, Action: func() { count++ }}})
+
+	// Holding the key repeats the press event.
+	d.Press(16)
+	d.Press(16)
+	assert.Equal(t, 0, count)
+	d.Release(16)
+
+	d.Press(18)
+	d.Release(18)
+	d.Press(16)
+	assert.Equal(t, 0, count)
+}
+
+func TestWaitUntilReleased(t *testing.T) {
+	d := NewDispatcher(testKeycodes)
+	d.Press(56)
+
+	assert.False(t, d.WaitUntilReleased(20*time.Millisecond))
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		d.Release(56)
+	}()
+	assert.True(t, d.WaitUntilReleased(time.Second))
+}
+
+func TestLastActivity(t *testing.T) {
+	d := NewDispatcher(testKeycodes)
+	created := d.LastActivity()
+	now := created.Add(time.Minute)
+	d.now = func() time.Time { return now }
+
+	d.Press(18)
+	assert.Equal(t, now, d.LastActivity())
+
+	d.Release(18)
+	assert.Equal(t, now, d.LastActivity())
+}
@@ -0,0 +1,145 @@
+package keyboard
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Key describes how to type a character that cannot be typed directly on a keyboard layout,
+// because it is only available as a dead key.
+type Key struct {
+	Character string
+	KeySym    int
+	// SpaceAfter is needed to type dead keys by themselves, because they modify the next character.
+	SpaceAfter bool
+}
+
+// AutoLayout is the layout name to derive the keys from the active keymap.
+const AutoLayout = "auto"
+
+const (
+	deadGrave      = 0xfe50
+	deadAcute      = 0xfe51
+	deadCircumflex = 0xfe52
+	deadTilde      = 0xfe53
+	deadDiaeresis  = 0xfe57
+)
+
+// deadChar is a character that can be typed with a dead key followed by a space.
+type deadChar struct {
+	character      string
+	keySymName     string
+	deadKeySymName string
+	deadKeySym     int
+}
+
+var deadChars = []deadChar{
+	{"`", "grave", "dead_grave", deadGrave},
+	{"'", "apostrophe", "dead_acute", deadAcute},
+	{"^", "asciicircum", "dead_circumflex", deadCircumflex},
+	{"~", "asciitilde", "dead_tilde", deadTilde},
+	{"\"", "quotedbl", "dead_diaeresis", deadDiaeresis},
+}
+
+func deadKey(character string) Key {
+	for _, d := range deadChars {
+		if d.character == character {
+			return Key{Character: d.character, KeySym: d.deadKeySym, SpaceAfter: true}
+		}
+	}
+	panic("no dead key for " + character)
+}
+
+func deadKeys(characters ...string) []Key {
+	var keys []Key
+	for _, c := range characters {
+		keys = append(keys, deadKey(c))
+	}
+	return keys
+}
+
+var presets = map[string][]Key{
+	"de_ch":   deadKeys("~", "^", "`"),
+	"fr_ch":   deadKeys("~", "^", "`"),
+	"de":      deadKeys("^", "`"),
+	"fr":      deadKeys("~", "^", "`"),
+	"es":      deadKeys("^", "`"),
+	"pt":      deadKeys("~", "^", "`"),
+	"se":      deadKeys("~", "^", "`"),
+	"no":      deadKeys("~", "^", "`"),
+	"dk":      deadKeys("~", "^", "`"),
+	"fi":      deadKeys("~", "^", "`"),
+	"us-intl": deadKeys("~", "^", "`", "'", "\""),
+	"us":      nil,
+	"gb":      nil,
+	"it":      nil,
+}
+
+// Layouts returns the names of the built-in layouts.
+func Layouts() []string {
+	var names []string
+	for n := range presets {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Keys returns the keys that have to be typed in a special way for the layout.
+// The "auto" layout derives them from the active keymap.
+func Keys(layout string) ([]Key, error) {
+	if layout == AutoLayout {
+		return Detect()
+	}
+
+	keys, ok := presets[layout]
+	if !ok {
+		return nil, fmt.Errorf("unknown keyboard layout %s, must be one of: %s, %s", layout, AutoLayout, strings.Join(Layouts(), ", "))
+	}
+	return keys, nil
+}
+
+// Detect derives the keys from the active X keymap, using xmodmap. It returns no keys on other OSes,
+// because typing only needs special keys on Linux.
+func Detect() ([]Key, error) {
+	if runtime.GOOS != "linux" {
+		return nil, nil
+	}
+
+	out, err := exec.Command("xmodmap", "-pke").Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not read keymap with xmodmap: %w", err)
+	}
+	return ParseXmodmap(string(out)), nil
+}
+
+// ParseXmodmap derives the keys from the output of xmodmap -pke. A character needs a special key if it
+// cannot be typed directly, i.e. without AltGr, but there is a dead key for it.
+func ParseXmodmap(keymap string) []Key {
+	direct := make(map[string]bool)
+	all := make(map[string]bool)
+	for _, line := range strings.Split(keymap, "\n") {
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(strings.TrimSpace(parts[0]), "keycode") {
+			continue
+		}
+		for i, keySym := range strings.Fields(parts[1]) {
+			all[keySym] = true
+			// The first two columns are the key without and with Shift.
+			if i < 2 {
+				direct[keySym] = true
+			}
+		}
+	}
+
+	var keys []Key
+	for _, d := range deadChars {
+		if !direct[d.keySymName] && all[d.deadKeySymName] {
+			keys = append(keys, deadKey(d.character))
+		}
+	}
+	return keys
+}
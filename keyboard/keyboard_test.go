@@ -0,0 +1,62 @@
+package keyboard
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const xmodmapDeCH = `keycode   9 = Escape NoSymbol Escape
+keycode  10 = 1 plus 1 plus bar exclamdown bar exclamdown
+keycode  11 = 2 quotedbl 2 quotedbl at oneeighth at oneeighth
+keycode  20 = apostrophe question apostrophe question dead_acute questiondown dead_acute questiondown
+keycode  21 = dead_circumflex dead_grave dead_circumflex dead_grave dead_tilde dead_ogonek dead_tilde dead_ogonek
+keycode  49 = section degree section degree notsign notsign notsign notsign
+keycode  65 = space NoSymbol space
+keycode 113 =
+`
+
+const xmodmapUS = `keycode  11 = 2 at 2 at
+keycode  15 = 6 asciicircum 6 asciicircum
+keycode  48 = apostrophe quotedbl apostrophe quotedbl
+keycode  49 = grave asciitilde grave asciitilde
+`
+
+func TestParseXmodmapDeCH(t *testing.T) {
+	keys := ParseXmodmap(xmodmapDeCH)
+
+	assert.Equal(t, []Key{
+		{Character: "`", KeySym: 0xfe50, SpaceAfter: true},
+		{Character: "^", KeySym: 0xfe52, SpaceAfter: true},
+		{Character: "~", KeySym: 0xfe53, SpaceAfter: true},
+	}, keys)
+}
+
+func TestParseXmodmapUS(t *testing.T) {
+	assert.Empty(t, ParseXmodmap(xmodmapUS))
+}
+
+func TestKeysPreset(t *testing.T) {
+	keys, err := Keys("de_ch")
+
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, ParseXmodmap(xmodmapDeCH), keys)
+}
+
+func TestKeysPresetFr(t *testing.T) {
+	keys, err := Keys("fr")
+
+	assert.Nil(t, err)
+	var chars []string
+	for _, k := range keys {
+		chars = append(chars, k.Character)
+	}
+	assert.ElementsMatch(t, []string{"~", "^", "`"}, chars)
+}
+
+func TestKeysUnknownLayout(t *testing.T) {
+	_, err := Keys("klingon")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown keyboard layout klingon, must be one of: auto, de, de_ch")
+}
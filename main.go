@@ -3,24 +3,36 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/driver/desktop"
-	"github.com/fsnotify/fsnotify"
 	"github.com/go-vgo/robotgo"
 	hook "github.com/robotn/gohook"
+	"github.com/sandro-h/snippet/audit"
+	"github.com/sandro-h/snippet/hotkey"
+	"github.com/sandro-h/snippet/keyboard"
+	"github.com/sandro-h/snippet/paths"
 	"github.com/sandro-h/snippet/secrets"
+	"github.com/sandro-h/snippet/session"
+	"github.com/sandro-h/snippet/store"
 	"github.com/sandro-h/snippet/typing"
+	"github.com/sandro-h/snippet/typing/robot"
 	"github.com/sandro-h/snippet/ui"
 	"github.com/sandro-h/snippet/util"
+	"github.com/sandro-h/snippet/watch"
 	"golang.org/x/crypto/ssh/terminal"
 	"gopkg.in/yaml.v2"
 )
@@ -29,82 +41,199 @@ type hotkeyConfig struct {
 	activateHotkeys []string
 	editorHotkeys   []string
 	editorCmd       string
+	// abortHotkeys stop typing a snippet when pressed abortPresses times in a row.
+	abortHotkeys []string
+	abortPresses int
+	undoHotkeys  []string
+	lockHotkeys  []string
+}
+
+type outputConfig struct {
+	// outputFile is the file that snippets with output: file append to, unless they have their own output_file.
+	outputFile string
 }
 
 type config struct {
 	typing.Config
 	secretTTL time.Duration
+	// idleTimeout locks all secrets after this long without key presses. Zero disables it.
+	idleTimeout time.Duration
+	// kdf are the KDF cost parameters for encrypting new secrets.
+	kdf secrets.KDFParams
+	// ageIdentity is the age identity file that decrypts secret_age snippets. If empty, age_identity.txt next to config.yml is used.
+	ageIdentity string
 	hotkeyConfig
+	profiles       map[string]*profile
+	defaultProfile string
+	// profile is the active profile, which has been applied to the config. Nil if no profile is active.
+	profile *profile
+	// variables resolve snippet arguments with the same name. They are set by the active profile.
+	variables map[string]string
+	// slowMode has the typing delays used when a snippet is submitted with Shift+Return.
+	slowMode typing.Config
+	outputConfig
+}
+
+// rawTypingSpeed are the typing delay settings, used globally and for slow mode.
+type rawTypingSpeed struct {
+	TypingDelay string `yaml:"typing_delay"`
+	LineDelay   string `yaml:"line_delay"`
+	ChunkSize   int    `yaml:"chunk_size"`
 }
 
 const defaultSecretTTL = 10 * time.Minute
 
+var defaultSlowMode = typing.Config{
+	TypingDelay: 30 * time.Millisecond,
+	LineDelay:   300 * time.Millisecond,
+	ChunkSize:   1,
+}
+
 var defaultActivateHotkeys = []string{"q", "alt"}
 
 var defaultEditorHotkeys = []string{"e", "alt"}
 
-var cfg *config = &config{
-	Config: typing.Config{
-		SpecialChars:    map[string]typing.SpecialChar{},
-		SpecialCharList: "",
-	},
-	secretTTL: defaultSecretTTL,
-	hotkeyConfig: hotkeyConfig{
-		activateHotkeys: defaultActivateHotkeys,
-		editorHotkeys:   defaultEditorHotkeys,
-	},
-}
+var defaultAbortHotkeys = []string{"escape"}
+
+var defaultUndoHotkeys = []string{"z", "alt"}
+
+var defaultLockHotkeys = []string{"l", "alt"}
+
+const defaultAbortPresses = 2
+
+// Without logind, check this often whether the machine was suspended.
+const sessionPollInterval = 5 * time.Second
+
+// Wait for this long after the last change to snippets.yml or config.yml before reloading them,
+// because editors usually cause several file events per save.
+const reloadDebounce = 200 * time.Millisecond
+
+var cfg *config = defaultConfig()
+
+// cfgLock guards cfg, which can be replaced when config.yml changes. A config itself is never modified.
+var cfgLock sync.RWMutex
 
 type appState struct {
-	snippets []*util.Snippet
+	store       *store.Store
+	errorBanner *ui.ErrorBanner
+	hotkeys     *hotkey.Dispatcher
+	mainWindow  fyne.Window
+	// passwordWindow asks for passwords outside of typing a secret snippet, e.g. for /generate.
+	passwordWindow fyne.Window
+	search         *ui.SearchWidget
+	watcher        *watch.Watcher
+	// snippetsFiles are the snippets files of the active profile. They are guarded by cfgLock, see currentSnippetsFiles.
+	snippetsFiles []string
+	// profileOverride is the profile given with --profile or SNIPPET_PROFILE. It is used instead of the active
+	// profile, without changing it for the CLI, until the user switches the profile. It is guarded by cfgLock.
+	profileOverride string
+	// evictorWake makes the secret eviction re-check right away, e.g. because the secret TTL changed.
+	evictorWake chan struct{}
 }
 
+const (
+	// unlockBackoff is the delay after the second failed password attempt. It doubles with every further failure.
+	unlockBackoff    = time.Second
+	maxUnlockBackoff = 5 * time.Minute
+)
+
+// auditLog records attempts to unlock secrets. It is in the state directory, see auditLogFile.
+var auditLog *audit.Log
+
+// unlockThrottle delays password attempts after repeated failures. The failures are saved in the state
+// directory, see unlockThrottleFile, so they are shared by the app and the CLI commands and survive restarts.
+var unlockThrottle *secrets.Throttle
+
 var doEncrypt = flag.Bool("encrypt", false, "Encrypt a secret")
 
+var configFlag = flag.String("config", "", "Config file to use instead of the default config.yml (env "+paths.ConfigEnv+")")
+
+var snippetsFlag stringsFlag
+
+var profileFlag = flag.String("profile", "", "Profile to use instead of the active profile (env "+profileEnv+")")
+
+func init() {
+	flag.Var(&snippetsFlag, "snippets", "Snippets file to use instead of the default snippets.yml, can be repeated (env "+paths.SnippetsEnv+")")
+}
+
+// files are the locations of config.yml and the snippets files, resolved from the flags and environment.
+var files *paths.Files
+
+// stringsFlag is a flag that can be given multiple times.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *stringsFlag) Set(val string) error {
+	*f = append(*f, val)
+	return nil
+}
+
 func main() {
+	flag.Usage = usage
 	flag.Parse()
+	typing.SetBackend(robot.Backend{})
 	if *doEncrypt {
 		encryptSecretFlow()
 		return
 	}
 
-	dir, _ := filepath.Abs(filepath.Dir(os.Args[0]))
-	configFile := filepath.Join(dir, "config.yml")
-	if _, err := os.Stat(configFile); !os.IsNotExist(err) {
-		cfg, err = loadConfig(configFile)
-		if err != nil {
-			panic(err)
-		}
+	var err error
+	files, err = paths.Resolve(paths.Overrides{Config: *configFlag, Snippets: snippetsFlag}, os.Getenv, appDir())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
 	}
 
-	state := &appState{}
+	auditLog = audit.New(auditLogFile())
+	unlockThrottle = secrets.NewFileThrottle(unlockThrottleFile(), unlockBackoff, maxUnlockBackoff)
 
-	snippetsFile := filepath.Join(dir, "snippets.yml")
-	if _, err := os.Stat(snippetsFile); os.IsNotExist(err) {
-		os.Create(snippetsFile)
+	if flag.NArg() > 0 {
+		runCommand(flag.Arg(0), flag.Args()[1:])
+		return
 	}
-	var err error
-	state.snippets, err = util.LoadSnippets(snippetsFile)
-	if err != nil {
-		panic(err)
+
+	// Make sure the default directories exist, so config.yml and the active profile can be watched
+	// even before they are created.
+	for _, d := range []string{files.Dir, files.StateDir} {
+		err = os.MkdirAll(d, 0700)
+		if err != nil {
+			log.Println("error creating directory:", err)
+		}
+	}
+	if files.IsDefault() {
+		createDefaultSnippetsFile()
 	}
 
 	a := app.New()
 	a.Settings().SetTheme(&ui.MyTheme{})
-	w := newWindow(a)
+	w := &searchWindow{Window: newWindow(a)}
+	state := &appState{
+		store:           store.New(nil),
+		hotkeys:         hotkey.NewDispatcher(hook.Keycode),
+		mainWindow:      w,
+		snippetsFiles:   files.Snippets,
+		profileOverride: profileOverride(),
+		evictorWake:     make(chan struct{}, 1),
+	}
 	argWin := ui.NewArgWindow(newWindow(a))
 	pwdWin := newWindow(a)
+	state.passwordWindow = pwdWin
 
-	search := ui.NewSearchWidget(state.snippets,
-		func(snippet *util.Snippet) {
+	search := ui.NewSearchWidget(state.store.Snapshot(),
+		func(snippet *util.Snippet, slow bool) {
 			w.Hide()
-			if snippet.Secret != "" {
-				typeSecretSnippet(snippet, w, pwdWin)
+			if snippet.SecretAge != "" {
+				typeAgeSecretSnippet(state, snippet, slow, w, pwdWin)
+			} else if snippet.Secret != "" {
+				typeSecretSnippet(state, snippet, slow, w, pwdWin)
 			} else if snippet.Args != nil {
-				typeArgSnippet(snippet, w, argWin)
+				typeArgSnippet(state, snippet, slow, w, argWin)
 
 			} else {
-				typing.TypeSnippet(snippet.Content, snippet.Copy, &cfg.Config)
+				outputSnippet(state, []byte(snippet.Content), snippet, slow)
 			}
 		},
 		func() {
@@ -112,34 +241,62 @@ func main() {
 		},
 	)
 
-	go watchSnippets(snippetsFile, func() {
-		snippets, err := util.ReloadSnippets(snippetsFile, state.snippets)
-		if err != nil {
-			log.Println("error reloading snippets.yml:", err)
-			return
-		}
+	state.search = search
+	w.search = search
+	search.OnCommand = func(name string, args []string) {
+		runSearchCommand(state, name, args)
+	}
 
-		// Heuristic for fsnotify double-loads where the first load can't read anything.
-		// That would destroy the old runtime state if we don't catch it here.
-		if len(snippets) == 0 {
-			return
+	state.store.Subscribe(func(e store.Event) {
+		if e.Kind == store.EventReplaced {
+			search.SetSnippets(e.Snippets)
 		}
+	})
+
+	state.errorBanner = ui.NewErrorBanner(func() {
+		w.Canvas().Focus(search.Entry)
+	})
+	reloadSnippets(state, true)
 
-		state.snippets = snippets
-		search.SetSnippets(snippets)
+	// Start with the default hotkeys, so they keep working if config.yml is invalid.
+	state.hotkeys.SetBindings(hotkeyBindings(state, currentConfig()))
+	reloadConfig(state)
+
+	state.watcher, err = watch.Watch(watchedFiles(state), reloadDebounce, func(changed []string) {
+		snippetsChanged := false
+		for _, f := range changed {
+			if f == files.Config || f == activeProfileFile() {
+				reloadConfig(state)
+			} else {
+				snippetsChanged = true
+			}
+		}
+		if snippetsChanged {
+			reloadSnippets(state, true)
+		}
 	})
+	if err != nil {
+		log.Println("error watching files:", err)
+		state.errorBanner.SetErrors("watch", []string{"Changes to config.yml and snippets are not picked up automatically: " + err.Error()})
+	} else {
+		defer state.watcher.Close()
+	}
 
 	split := container.NewVSplit(search.Entry, search.List)
 	split.Offset = 0
-	w.SetContent(split)
+	w.SetContent(container.NewBorder(state.errorBanner.Container, nil, nil, nil, split))
 	w.Resize(fyne.NewSize(400, 250))
 	w.Canvas().Focus(search.Entry)
 	w.CenterOnScreen()
 
-	go listenForHotkeys(w, snippetsFile, cfg.hotkeyConfig)
-	go periodicallyEvictSecrets(state, cfg.secretTTL)
+	go listenForHotkeys(state.hotkeys)
+	go periodicallyEvictSecrets(state)
+	go quitOnSignal(a)
+	defer watchSession(state).Close()
 
 	w.ShowAndRun()
+	// Wipe the unlocked secrets before exiting.
+	state.store.EvictAllSecrets()
 }
 
 func encryptSecretFlow() {
@@ -154,7 +311,9 @@ func encryptSecretFlow() {
 	if err != nil {
 		panic(err)
 	}
-	enc, err := secrets.Encrypt(string(secret), string(password))
+	enc, err := secrets.Encrypt(secret, password)
+	secrets.Wipe(secret)
+	secrets.Wipe(password)
 	if err != nil {
 		panic(err)
 	}
@@ -162,6 +321,174 @@ func encryptSecretFlow() {
 	fmt.Println(enc)
 }
 
+// auditLogFile is the file that auditLog appends to.
+func auditLogFile() string {
+	return filepath.Join(files.StateDir, "audit.log")
+}
+
+// unlockThrottleFile is the file with the failed password attempts of unlockThrottle.
+func unlockThrottleFile() string {
+	return filepath.Join(files.StateDir, "unlock_failures.json")
+}
+
+// appDir returns the directory of the snippet binary, following symlinks. os.Args[0] cannot be used, since
+// it is only a name if the binary is started from $PATH. It returns an empty string if the binary cannot be found.
+func appDir() string {
+	exe, err := os.Executable()
+	if err != nil {
+		log.Println("error finding the snippet binary:", err)
+		return ""
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		log.Println("error finding the snippet binary:", err)
+		return ""
+	}
+	return filepath.Dir(exe)
+}
+
+// createDefaultSnippetsFile creates an empty snippets file in the default location, if it does not exist yet.
+func createDefaultSnippetsFile() {
+	if _, err := os.Stat(files.Snippets[0]); !os.IsNotExist(err) {
+		return
+	}
+	f, err := os.Create(files.Snippets[0])
+	if err != nil {
+		log.Println("error creating snippets file:", err)
+		return
+	}
+	f.Close()
+}
+
+func currentConfig() *config {
+	cfgLock.RLock()
+	defer cfgLock.RUnlock()
+	return cfg
+}
+
+// currentSnippetsFiles returns the snippets files of the active profile. The slice is replaced, never modified,
+// when the profile changes, so it can be used without holding the lock.
+func currentSnippetsFiles(state *appState) []string {
+	cfgLock.RLock()
+	defer cfgLock.RUnlock()
+	return state.snippetsFiles
+}
+
+// loadAppConfig loads config.yml, or returns the default config if there is no config.yml.
+func loadAppConfig() (*config, error) {
+	if _, err := os.Stat(files.Config); os.IsNotExist(err) {
+		return defaultConfig(), nil
+	}
+	return loadConfig(files.Config)
+}
+
+// loadActiveConfig loads config.yml and applies the active profile.
+// The profile given with --profile takes precedence over the active profile.
+func loadActiveConfig(profileOverride string) (*config, error) {
+	c, err := loadAppConfig()
+	if err != nil {
+		return nil, err
+	}
+	return c.withProfile(activeProfileName(c, profileOverride))
+}
+
+// reloadConfig loads config.yml and the active profile and applies them to the running app: hotkeys are
+// re-registered, typing uses the new special chars, the secret eviction re-checks with the new TTL and
+// the snippets files of the profile are loaded.
+// An invalid config is rejected and the previous config is kept. The problem is shown in the error banner.
+func reloadConfig(state *appState) {
+	cfgLock.RLock()
+	override := state.profileOverride
+	cfgLock.RUnlock()
+
+	c, err := loadActiveConfig(override)
+	if err == nil {
+		err = state.hotkeys.SetBindings(hotkeyBindings(state, c))
+	}
+	if err != nil {
+		log.Println("error loading config.yml:", err)
+		state.errorBanner.SetErrors("config", []string{"Invalid config.yml, keeping previous config: " + err.Error()})
+		return
+	}
+	state.errorBanner.SetErrors("config", nil)
+
+	prev := currentConfig()
+	cfgLock.Lock()
+	cfg = c
+	cfgLock.Unlock()
+
+	profileChanged := c.profileName() != prev.profileName()
+	if profileChanged {
+		onProfileChanged(state, c)
+	}
+
+	if snippetsFiles := snippetsFilesFor(c); !equalStrings(snippetsFiles, currentSnippetsFiles(state)) {
+		cfgLock.Lock()
+		state.snippetsFiles = snippetsFiles
+		cfgLock.Unlock()
+		if state.watcher != nil {
+			err = state.watcher.SetFiles(watchedFiles(state))
+			if err != nil {
+				log.Println("error watching files:", err)
+				state.errorBanner.SetErrors("watch", []string{"Changes to snippets are not picked up automatically: " + err.Error()})
+			}
+		}
+		reloadSnippets(state, !profileChanged)
+	}
+
+	select {
+	case state.evictorWake <- struct{}{}:
+	default:
+	}
+}
+
+// reloadSnippets loads the snippets files into the store. If any file cannot be loaded at all,
+// the previous snippets are kept if keepPrevious is true. Otherwise, e.g. because the previous snippets
+// belong to another profile, the store is emptied. All problems are shown in the error banner.
+func reloadSnippets(state *appState, keepPrevious bool) {
+	snippets, snippetErrs, err := util.LoadSnippetFiles(currentSnippetsFiles(state))
+	if err != nil {
+		log.Println("error reloading snippets:", err)
+		if keepPrevious {
+			state.errorBanner.SetErrors("snippets", []string{"Could not load snippets, keeping previous snippets: " + err.Error()})
+			return
+		}
+		state.errorBanner.SetErrors("snippets", []string{"Could not load snippets: " + err.Error()})
+		state.store.Replace(nil)
+		return
+	}
+
+	var errs []string
+	for _, e := range snippetErrs {
+		log.Println(e)
+		errs = append(errs, e.Error())
+	}
+	state.errorBanner.SetErrors("snippets", errs)
+	state.store.Replace(snippets)
+}
+
+// searchWindow is the main window with the search widget. The countdowns of TOTP snippets in the search list
+// only run while it is shown.
+type searchWindow struct {
+	fyne.Window
+	search *ui.SearchWidget
+}
+
+func (w *searchWindow) Show() {
+	w.Window.Show()
+	w.search.StartCountdowns()
+}
+
+func (w *searchWindow) Hide() {
+	w.search.StopCountdowns()
+	w.Window.Hide()
+}
+
+func (w *searchWindow) ShowAndRun() {
+	w.search.StartCountdowns()
+	w.Window.ShowAndRun()
+}
+
 func newWindow(a fyne.App) fyne.Window {
 	if drv, ok := a.Driver().(desktop.Driver); ok {
 		return drv.CreateSplashWindow()
@@ -169,6 +496,28 @@ func newWindow(a fyne.App) fyne.Window {
 	return a.NewWindow("")
 }
 
+func defaultConfig() *config {
+	return &config{
+		Config: typing.Config{
+			SpecialChars:    map[string]typing.SpecialChar{},
+			SpecialCharList: "",
+			LineDelay:       typing.DefaultLineDelay,
+		},
+		secretTTL: defaultSecretTTL,
+		kdf:       secrets.DefaultKDFParams,
+		hotkeyConfig: hotkeyConfig{
+			activateHotkeys: defaultActivateHotkeys,
+			editorHotkeys:   defaultEditorHotkeys,
+			abortHotkeys:    defaultAbortHotkeys,
+			abortPresses:    defaultAbortPresses,
+			undoHotkeys:     defaultUndoHotkeys,
+			lockHotkeys:     defaultLockHotkeys,
+		},
+		profiles: map[string]*profile{},
+		slowMode: defaultSlowMode,
+	}
+}
+
 func loadConfig(configFile string) (*config, error) {
 	bytes, err := os.ReadFile(configFile)
 	if err != nil {
@@ -176,26 +525,56 @@ func loadConfig(configFile string) (*config, error) {
 	}
 
 	var rawCfg struct {
-		SpecialCharList []typing.SpecialChar `yaml:"special_chars"`
-		SecretTTL       string               `yaml:"secret_ttl"`
-		EditorCmd       string               `yaml:"editor_cmd"`
-		ActivateHotkeys []string             `yaml:"activate_hotkeys"`
-		EditorHotkeys   []string             `yaml:"editor_hotkeys"`
+		KeyboardLayout  string                `yaml:"keyboard_layout"`
+		SpecialCharList []typing.SpecialChar  `yaml:"special_chars"`
+		SecretTTL       string                `yaml:"secret_ttl"`
+		IdleTimeout     string                `yaml:"idle_timeout"`
+		EditorCmd       string                `yaml:"editor_cmd"`
+		ActivateHotkeys []string              `yaml:"activate_hotkeys"`
+		EditorHotkeys   []string              `yaml:"editor_hotkeys"`
+		AbortHotkeys    []string              `yaml:"abort_hotkeys"`
+		AbortPresses    int                   `yaml:"abort_presses"`
+		UndoHotkeys     []string              `yaml:"undo_hotkeys"`
+		LockHotkeys     []string              `yaml:"lock_hotkeys"`
+		OutputFile      string                `yaml:"output_file"`
+		KDF             secrets.KDFParams     `yaml:"kdf"`
+		AgeIdentity     string                `yaml:"age_identity"`
+		Profiles        map[string]rawProfile `yaml:"profiles"`
+		DefaultProfile  string                `yaml:"default_profile"`
+		rawTypingSpeed  `yaml:",inline"`
+		SlowMode        rawTypingSpeed `yaml:"slow_mode"`
 	}
+	// Parameters missing in config.yml keep their default.
+	rawCfg.KDF = secrets.DefaultKDFParams
 	err = yaml.Unmarshal(bytes, &rawCfg)
 	if err != nil {
 		return nil, err
 	}
+	if err := rawCfg.KDF.Validate(); err != nil {
+		return nil, fmt.Errorf("kdf: %w", err)
+	}
 
 	cfg := config{
 		Config: typing.Config{
 			SpecialChars:    map[string]typing.SpecialChar{},
 			SpecialCharList: "",
+			LineDelay:       typing.DefaultLineDelay,
 		},
 		secretTTL: defaultSecretTTL,
+		kdf:       rawCfg.KDF,
 		hotkeyConfig: hotkeyConfig{
 			editorCmd: rawCfg.EditorCmd,
 		},
+		profiles:       map[string]*profile{},
+		defaultProfile: rawCfg.DefaultProfile,
+		slowMode:       defaultSlowMode,
+	}
+
+	if err := rawCfg.rawTypingSpeed.apply(&cfg.Config, ""); err != nil {
+		return nil, err
+	}
+	if err := rawCfg.SlowMode.apply(&cfg.slowMode, "slow_mode."); err != nil {
+		return nil, err
 	}
 
 	if rawCfg.ActivateHotkeys != nil {
@@ -210,133 +589,480 @@ func loadConfig(configFile string) (*config, error) {
 		cfg.editorHotkeys = defaultEditorHotkeys
 	}
 
+	if rawCfg.AbortHotkeys != nil {
+		cfg.abortHotkeys = rawCfg.AbortHotkeys
+	} else {
+		cfg.abortHotkeys = defaultAbortHotkeys
+	}
+
+	if rawCfg.OutputFile != "" {
+		cfg.outputFile, err = util.ResolvePath(rawCfg.OutputFile, filepath.Dir(configFile))
+		if err != nil {
+			return nil, fmt.Errorf("output_file: %w", err)
+		}
+	}
+
+	if rawCfg.AgeIdentity != "" {
+		cfg.ageIdentity, err = util.ResolvePath(rawCfg.AgeIdentity, filepath.Dir(configFile))
+		if err != nil {
+			return nil, fmt.Errorf("age_identity: %w", err)
+		}
+	}
+
+	if rawCfg.UndoHotkeys != nil {
+		cfg.undoHotkeys = rawCfg.UndoHotkeys
+	} else {
+		cfg.undoHotkeys = defaultUndoHotkeys
+	}
+
+	if rawCfg.LockHotkeys != nil {
+		cfg.lockHotkeys = rawCfg.LockHotkeys
+	} else {
+		cfg.lockHotkeys = defaultLockHotkeys
+	}
+
+	switch {
+	case rawCfg.AbortPresses < 0:
+		return nil, fmt.Errorf("abort_presses must be positive, but was %d", rawCfg.AbortPresses)
+	case rawCfg.AbortPresses > 0:
+		cfg.abortPresses = rawCfg.AbortPresses
+	default:
+		cfg.abortPresses = defaultAbortPresses
+	}
+
 	if rawCfg.SecretTTL != "" {
-		dur, err := time.ParseDuration(rawCfg.SecretTTL)
+		cfg.secretTTL, err = parseSecretTTL(rawCfg.SecretTTL)
 		if err != nil {
 			return nil, err
 		}
-		cfg.secretTTL = dur
 	}
 
+	if rawCfg.IdleTimeout != "" {
+		cfg.idleTimeout, err = time.ParseDuration(rawCfg.IdleTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid idle_timeout: %w", err)
+		}
+		if cfg.idleTimeout < 0 {
+			return nil, fmt.Errorf("idle_timeout must not be negative, but was %s", rawCfg.IdleTimeout)
+		}
+	}
+
+	for name, raw := range rawCfg.Profiles {
+		p, err := raw.toProfile(name, filepath.Dir(configFile))
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+		cfg.profiles[name] = p
+	}
+	if _, ok := cfg.profiles[cfg.defaultProfile]; cfg.defaultProfile != "" && !ok {
+		return nil, fmt.Errorf("default_profile: unknown profile %s", cfg.defaultProfile)
+	}
+
+	if rawCfg.KeyboardLayout != "" {
+		keys, err := keyboard.Keys(rawCfg.KeyboardLayout)
+		if err != nil && rawCfg.KeyboardLayout == keyboard.AutoLayout {
+			// Detecting the keymap fails e.g. if xmodmap is not installed. That must not reject the whole config.
+			log.Printf("keyboard_layout: %s, using the default layout", err)
+			keys, err = nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("keyboard_layout: %w", err)
+		}
+		for _, k := range keys {
+			cfg.SpecialChars[k.Character] = typing.SpecialChar{Character: k.Character, KeySym: k.KeySym, SpaceAfter: k.SpaceAfter}
+		}
+	}
+
+	// special_chars override the keyboard layout's special chars.
 	for _, s := range rawCfg.SpecialCharList {
+		if utf8.RuneCountInString(s.Character) != 1 {
+			return nil, fmt.Errorf("special_chars: character must be a single character, but was '%s'", s.Character)
+		}
+		if s.KeySym <= 0 {
+			return nil, fmt.Errorf("special_chars: missing key_sym for character '%s'", s.Character)
+		}
 		cfg.SpecialChars[s.Character] = s
-		cfg.SpecialCharList += s.Character
+	}
+
+	for c := range cfg.SpecialChars {
+		cfg.SpecialCharList += c
 	}
 
 	return &cfg, nil
 }
 
-func watchSnippets(snippetsFile string, onModified func()) {
-	watcher, err := fsnotify.NewWatcher()
+func parseSecretTTL(str string) (time.Duration, error) {
+	dur, err := time.ParseDuration(str)
 	if err != nil {
-		panic(err)
+		return 0, fmt.Errorf("invalid secret_ttl: %w", err)
 	}
-	defer watcher.Close()
-
-	err = watcher.Add(snippetsFile)
-	if err != nil {
-		panic(err)
+	if dur <= 0 {
+		return 0, fmt.Errorf("secret_ttl must be positive, but was %s", str)
 	}
+	return dur, nil
+}
 
-	for {
-		select {
-		case event, ok := <-watcher.Events:
-			if !ok {
-				return
-			}
-			if event.Op&fsnotify.Write == fsnotify.Write {
-				onModified()
-			}
-		case err, ok := <-watcher.Errors:
-			if !ok {
-				return
-			}
-			log.Println("error:", err)
+// apply sets the typing delays that are configured. prefix is used for error messages.
+func (r rawTypingSpeed) apply(c *typing.Config, prefix string) error {
+	for _, field := range []struct {
+		name string
+		str  string
+		dest *time.Duration
+	}{{"typing_delay", r.TypingDelay, &c.TypingDelay}, {"line_delay", r.LineDelay, &c.LineDelay}} {
+		if field.str == "" {
+			continue
 		}
+		dur, err := time.ParseDuration(field.str)
+		if err != nil {
+			return fmt.Errorf("invalid %s%s: %w", prefix, field.name, err)
+		}
+		if dur < 0 {
+			return fmt.Errorf("%s%s must not be negative, but was %s", prefix, field.name, field.str)
+		}
+		*field.dest = dur
+	}
+
+	if r.ChunkSize < 0 {
+		return fmt.Errorf("%schunk_size must be positive, but was %d", prefix, r.ChunkSize)
+	}
+	if r.ChunkSize > 0 {
+		c.ChunkSize = r.ChunkSize
 	}
+	return nil
 }
 
-func listenForHotkeys(w fyne.Window, snippetsFile string, hotkeyCfg hotkeyConfig) {
-	robotgo.EventHook(hook.KeyDown, hotkeyCfg.activateHotkeys, func(e hook.Event) {
-		w.Show()
+// typingConfig returns the typing config for the snippet. In slow mode, the slow mode delays override
+// the global and snippet settings.
+func (c *config) typingConfig(snippet *util.Snippet, slow bool) *typing.Config {
+	res := c.Config.With(snippet.Typing)
+	if slow {
+		res.TypingDelay = c.slowMode.TypingDelay
+		res.LineDelay = c.slowMode.LineDelay
+		res.ChunkSize = c.slowMode.ChunkSize
+	}
+	return res
+}
+
+// listenForHotkeys passes all key events to the dispatcher. The hooks are registered only once,
+// because robotgo's hooks cannot be changed while processing events. The dispatcher's bindings can.
+func listenForHotkeys(dispatcher *hotkey.Dispatcher) {
+	robotgo.EventHook(hook.KeyHold, []string{}, func(e hook.Event) {
+		dispatcher.Press(e.Keycode)
+	})
+	robotgo.EventHook(hook.KeyUp, []string{}, func(e hook.Event) {
+		dispatcher.Release(e.Keycode)
 	})
 
-	if hotkeyCfg.editorCmd != "" {
-		editorCmdParts := strings.Split(hotkeyCfg.editorCmd, " ")
-		editorCmdParts = append(editorCmdParts, snippetsFile)
-		robotgo.EventHook(hook.KeyDown, hotkeyCfg.editorHotkeys, func(e hook.Event) {
+	s := robotgo.EventStart()
+	<-robotgo.EventProcess(s)
+}
+
+func hotkeyBindings(state *appState, c *config) []hotkey.Binding {
+	bindings := []hotkey.Binding{
+		{Keys: c.activateHotkeys, Action: state.mainWindow.Show},
+	}
+
+	if len(c.abortHotkeys) > 0 {
+		bindings = append(bindings, hotkey.Binding{Keys: c.abortHotkeys, Presses: c.abortPresses, Action: func() {
+			if typing.Abort() {
+				log.Println("Aborted typing snippet")
+			}
+		}})
+	}
+
+	if len(c.undoHotkeys) > 0 {
+		bindings = append(bindings, hotkey.Binding{Keys: c.undoHotkeys, Action: func() {
+			go func() {
+				state.hotkeys.WaitUntilReleased(undoReleaseTimeout)
+				if err := undoLastInsert(); err != nil {
+					log.Println("Could not undo snippet:", err)
+				}
+			}()
+		}})
+	}
+
+	if len(c.lockHotkeys) > 0 {
+		bindings = append(bindings, hotkey.Binding{Keys: c.lockHotkeys, Action: func() {
+			lockAll(state, "lock hotkey")
+		}})
+	}
+
+	if c.editorCmd != "" {
+		editorCmdParts := strings.Split(c.editorCmd, " ")
+		editorCmdParts = append(editorCmdParts, snippetsFilesFor(c)...)
+		bindings = append(bindings, hotkey.Binding{Keys: c.editorHotkeys, Action: func() {
 			cmd := exec.Command(editorCmdParts[0], editorCmdParts[1:]...)
 			err := cmd.Start()
 			if err != nil {
 				log.Printf("Could not run %s: %s", cmd, err)
 			}
-		})
+		}})
 	}
 
-	s := robotgo.EventStart()
-	<-robotgo.EventProcess(s)
-}
-
-func typeArgSnippet(snippet *util.Snippet, mainWindow fyne.Window, argWin *ui.ArgWindow) {
-	var inputArgs []string
-	vals := make(map[string]string)
-	for _, arg := range snippet.Args {
-		switch arg.Resolver.(type) {
-		case *util.ManualResolver:
-			inputArgs = append(inputArgs, arg.Name)
-		default:
-			vals[arg.Name] = arg.Resolver.Resolve()
+	for _, name := range c.profileNames() {
+		if keys := c.profiles[name].switchHotkeys; keys != nil {
+			name := name
+			bindings = append(bindings, hotkey.Binding{Keys: keys, Action: func() {
+				switchProfile(state, name)
+			}})
 		}
 	}
 
+	return bindings
+}
+
+func typeArgSnippet(state *appState, snippet *util.Snippet, slow bool, mainWindow fyne.Window, argWin *ui.ArgWindow) {
+	vals, inputArgs, err := util.ResolveArgs(snippet, currentConfig().variables)
+	if err != nil {
+		log.Printf("Could not output snippet %s: %s", snippet.Label, err)
+		state.errorBanner.SetErrors("output", []string{fmt.Sprintf("Could not output snippet %s: %s", snippet.Label, err)})
+		mainWindow.Show()
+		return
+	}
 	if len(inputArgs) > 0 {
 		argWin.ShowWithArgs(inputArgs, func(inputVals map[string]string) {
 			for k, v := range inputVals {
 				vals[k] = v
 			}
-			typing.TypeSnippet(util.InstantiateArgs(snippet.Content, vals), snippet.Copy, &cfg.Config)
+			outputSnippet(state, []byte(util.InstantiateArgs(snippet.Content, vals)), snippet, slow)
 		}, func() {
 			mainWindow.Show()
 		})
 	} else {
-		typing.TypeSnippet(util.InstantiateArgs(snippet.Content, vals), snippet.Copy, &cfg.Config)
+		outputSnippet(state, []byte(util.InstantiateArgs(snippet.Content, vals)), snippet, slow)
 	}
 }
 
-func typeSecretSnippet(snippet *util.Snippet, mainWindow fyne.Window, pwdWindow fyne.Window) {
-	if snippet.SecretDecrypted == "" {
-		ui.ShowPasswordWindow(pwdWindow, "Password for secret "+snippet.Label,
-			func(pwd string) {
+func typeSecretSnippet(state *appState, snippet *util.Snippet, slow bool, mainWindow fyne.Window, pwdWindow fyne.Window) {
+	if secrets.IsMasterSecret(snippet.Secret) {
+		typeMasterSecretSnippet(state, snippet, slow, mainWindow, pwdWindow)
+		return
+	}
+
+	if decrypted, ok := state.store.UnlockedSecret(snippet.Label); ok {
+		outputSnippet(state, decrypted, snippet, slow)
+		return
+	}
+
+	var decrypted []byte
+	ui.ShowPasswordWindow(pwdWindow, "Password for secret "+snippet.Label,
+		func(pwd string) error {
+			return tryUnlock("secret:"+snippet.Label, snippet.Label, func() error {
 				var err error
-				snippet.SecretDecrypted, err = secrets.Decrypt(snippet.Secret, pwd)
-				if err != nil {
-					log.Printf("Could not type secret snippet %s: %s", snippet.Label, err)
-					return
-				}
-				snippet.SecretLastUsed = time.Now()
-				typing.TypeSnippet(snippet.SecretDecrypted, snippet.Copy, &cfg.Config)
-			},
-			func() {
-				mainWindow.Show()
-			},
-		)
+				decrypted, err = secrets.Decrypt(snippet.Secret, pwd)
+				return err
+			})
+		},
+		func() {
+			state.store.UnlockSecret(snippet.Label, decrypted)
+			outputSnippet(state, decrypted, snippet, slow)
+		},
+		func() {
+			mainWindow.Show()
+		},
+	)
+}
+
+// typeAgeSecretSnippet types a secret that is encrypted with age. The passphrase of the age identity file is only
+// asked for if the identity file is encrypted.
+func typeAgeSecretSnippet(state *appState, snippet *util.Snippet, slow bool, mainWindow fyne.Window, pwdWindow fyne.Window) {
+	if decrypted, ok := state.store.UnlockedSecret(snippet.Label); ok {
+		outputSnippet(state, decrypted, snippet, slow)
+		return
+	}
+
+	identityFile, err := readAgeIdentityFile(currentConfig())
+	if err != nil {
+		log.Printf("Could not type secret snippet %s: %s", snippet.Label, err)
+		state.errorBanner.SetErrors("secrets", []string{err.Error()})
+		return
+	}
+	state.errorBanner.SetErrors("secrets", nil)
+
+	var decrypted []byte
+	decrypt := func(passphrase string) error {
+		var err error
+		decrypted, err = decryptAgeSecret(snippet.SecretAge, identityFile, passphrase)
+		return err
+	}
+	output := func() {
+		state.store.UnlockSecret(snippet.Label, decrypted)
+		outputSnippet(state, decrypted, snippet, slow)
+	}
+
+	if !secrets.IsAgeIdentityEncrypted(identityFile) {
+		err = decrypt("")
+		if err != nil {
+			log.Printf("Could not type secret snippet %s: %s", snippet.Label, err)
+			return
+		}
+		output()
+		return
+	}
+
+	ui.ShowPasswordWindow(pwdWindow, "Passphrase for age identity",
+		func(passphrase string) error {
+			return tryUnlock("age identity", snippet.Label, func() error {
+				return decrypt(passphrase)
+			})
+		},
+		output,
+		func() {
+			mainWindow.Show()
+		},
+	)
+}
+
+// typeMasterSecretSnippet types a secret that is encrypted with the master key. The master password is
+// only asked for if the master key is locked. The unlocked master key is evicted after the secret TTL.
+func typeMasterSecretSnippet(state *appState, snippet *util.Snippet, slow bool, mainWindow fyne.Window, pwdWindow fyne.Window) {
+	typeWithKey := func(key []byte) {
+		defer secrets.Wipe(key)
+		decrypted, err := secrets.DecryptWithMasterKey(snippet.Secret, key)
+		if err != nil {
+			log.Printf("Could not type secret snippet %s: %s", snippet.Label, err)
+			return
+		}
+		outputSnippet(state, decrypted, snippet, slow)
+	}
+
+	if key, ok := state.store.UnlockedMasterKey(); ok {
+		typeWithKey(key)
+		return
+	}
+
+	master, err := loadMasterKey()
+	if err != nil {
+		log.Printf("Could not type secret snippet %s: %s", snippet.Label, err)
+		state.errorBanner.SetErrors("secrets", []string{err.Error()})
+		return
+	}
+	state.errorBanner.SetErrors("secrets", nil)
+
+	var key []byte
+	ui.ShowPasswordWindow(pwdWindow, "Master password",
+		func(pwd string) error {
+			return tryUnlock("master key", snippet.Label, func() error {
+				var err error
+				key, err = master.Unlock(pwd)
+				return err
+			})
+		},
+		func() {
+			state.store.UnlockMasterKey(key)
+			typeWithKey(key)
+		},
+		func() {
+			mainWindow.Show()
+		},
+	)
+}
+
+// tryUnlock runs unlock with a password from the password window or a CLI prompt. After repeated failures,
+// further attempts are refused for a while, exponentially longer with each failure. Attempts are throttled per
+// throttleKey, the thing the password unlocks, and recorded in the audit log with the snippet label. The returned
+// error is shown to the user.
+func tryUnlock(throttleKey string, label string, unlock func() error) error {
+	if wait := unlockThrottle.Wait(throttleKey, time.Now()); wait > 0 {
+		recordAudit(audit.UnlockThrottled, label)
+		return fmt.Errorf("too many failed attempts, try again in %s", roundUpToSeconds(wait))
+	}
+
+	recordAudit(audit.UnlockAttempted, label)
+	err := unlock()
+	recordUnlockResult(label, err)
+	if err != nil {
+		log.Printf("Could not unlock secret snippet %s: %s", label, err)
+		if wait := unlockThrottle.Failed(throttleKey, time.Now()); wait > 0 {
+			return fmt.Errorf("%w, try again in %s", err, roundUpToSeconds(wait))
+		}
+		return err
+	}
+	unlockThrottle.Succeeded(throttleKey)
+	return nil
+}
+
+func roundUpToSeconds(d time.Duration) time.Duration {
+	return (d + time.Second - 1).Truncate(time.Second)
+}
+
+// recordAudit records the event in the audit log. Unlocking and changing secrets still works if the audit
+// log cannot be written.
+func recordAudit(event audit.Event, label string) {
+	err := auditLog.Record(event, label)
+	if err != nil {
+		log.Println("error writing audit log:", err)
+	}
+}
+
+// recordUnlockResult records in the audit log whether unlocking succeeded, depending on the error of the unlock.
+func recordUnlockResult(label string, err error) {
+	if err != nil {
+		recordAudit(audit.UnlockFailed, label)
 	} else {
-		snippet.SecretLastUsed = time.Now()
-		typing.TypeSnippet(snippet.SecretDecrypted, snippet.Copy, &cfg.Config)
+		recordAudit(audit.UnlockSucceeded, label)
+	}
+}
+
+// lockAll locks the master key and all unlocked secrets. The reason is logged.
+func lockAll(state *appState, reason string) {
+	state.store.EvictAllSecrets()
+	log.Printf("Locked all secrets: %s", reason)
+}
+
+// watchSession locks all secrets when the screen is locked or the machine suspends. Without logind,
+// e.g. on other OSes, only suspends are detected by polling the clocks.
+func watchSession(state *appState) io.Closer {
+	onEvent := func(e session.Event) {
+		lockAll(state, e.String())
+	}
+	w, err := session.WatchLogind(onEvent)
+	if err != nil {
+		log.Println("Could not watch logind, only suspends lock the secrets:", err)
+		return session.Poll(sessionPollInterval, nil, onEvent)
 	}
+	return w
+}
+
+// quitOnSignal quits the app on SIGINT and SIGTERM, so the unlocked secrets are wiped before exiting.
+func quitOnSignal(a fyne.App) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	a.Quit()
 }
 
-func periodicallyEvictSecrets(state *appState, ttl time.Duration) {
+func periodicallyEvictSecrets(state *appState) {
+	idleLocked := false
 	for {
-		now := time.Now()
-		for _, s := range state.snippets {
-			if s.SecretDecrypted != "" && now.Sub(s.SecretLastUsed) > ttl {
-				s.SecretDecrypted = ""
-			}
+		c := currentConfig()
+		state.store.EvictSecrets(c.secretTTL, time.Now())
+
+		// Lock once when the user becomes idle, not on every check while idle.
+		idle := c.idleTimeout > 0 && time.Since(state.hotkeys.LastActivity()) > c.idleTimeout
+		if idle && !idleLocked {
+			lockAll(state, "idle")
 		}
+		idleLocked = idle
 
-		// Use 30s interval by default, except if ttl/2 is lower than that.
-		// But do max 1 check per second.
-		time.Sleep(util.MaxDur(1*time.Second, util.MinDur(30*time.Second, ttl/2)))
+		select {
+		case <-time.After(evictionInterval(c, state.store.Snapshot())):
+		case <-state.evictorWake:
+		}
+	}
+}
+
+// evictionInterval returns how long to wait until the next eviction check. It uses 30s by default, except if half
+// of a secret TTL or the idle timeout is lower than that. But it does max 1 check per second.
+func evictionInterval(c *config, snippets []*util.Snippet) time.Duration {
+	interval := util.MinDur(30*time.Second, c.secretTTL/2)
+	if c.idleTimeout > 0 {
+		interval = util.MinDur(interval, c.idleTimeout/2)
+	}
+	for _, s := range snippets {
+		if s.SecretTTL > 0 {
+			interval = util.MinDur(interval, s.SecretTTL/2)
+		}
 	}
+	return util.MaxDur(1*time.Second, interval)
 }
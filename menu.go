@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/sandro-h/snippet/menu"
+	"github.com/sandro-h/snippet/secrets"
+	"github.com/sandro-h/snippet/typing"
+	"github.com/sandro-h/snippet/util"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// menuPrompts ask the user for a value for the label, using the launcher of the menu format.
+var menuPrompts = map[string]func(label string, masked bool) (string, bool, error){
+	"dmenu": func(label string, masked bool) (string, bool, error) {
+		if masked {
+			return "", false, errors.New("dmenu cannot hide password input, use rofi or fzf for secret snippets")
+		}
+		return launcherPrompt("dmenu", "-p", label)
+	},
+	"rofi": func(label string, masked bool) (string, bool, error) {
+		if masked {
+			return launcherPrompt("rofi", "-dmenu", "-password", "-p", label)
+		}
+		return launcherPrompt("rofi", "-dmenu", "-p", label)
+	},
+	"fzf": func(label string, masked bool) (string, bool, error) {
+		if masked {
+			return ttyPassword(label)
+		}
+		return launcherPrompt("fzf", "--print-query", "--prompt", label+"> ")
+	},
+}
+
+// Give the launcher window some time to close and return the focus before typing.
+const menuTypeDelay = 100 * time.Millisecond
+
+// menuPrompter prompts for snippet input with a launcher.
+type menuPrompter struct {
+	prompt func(label string, masked bool) (string, bool, error)
+	err    error
+}
+
+func (p *menuPrompter) Prompt(label string, masked bool) (string, bool) {
+	val, ok, err := p.prompt(label, masked)
+	if err != nil {
+		p.err = err
+	}
+	return val, ok
+}
+
+// runMenu lists the snippets for a dmenu-like launcher, or types the snippet of the line chosen in the launcher.
+//
+//	snippet menu --format rofi | rofi -dmenu -markup-rows | snippet menu --format rofi --select
+func runMenu(args []string) error {
+	fs := flag.NewFlagSet("menu", flag.ExitOnError)
+	format := fs.String("format", "dmenu", "Line format of the launcher: "+strings.Join(menu.Formats(), ", "))
+	doSelect := fs.Bool("select", false, "Type the snippet of the chosen line, read from the arguments or stdin")
+	fs.Parse(args)
+
+	err := menu.Validate(*format)
+	if err != nil {
+		return err
+	}
+
+	snippets, c, err := loadSnippets()
+	if err != nil {
+		return err
+	}
+
+	if !*doSelect {
+		for _, s := range snippets {
+			fmt.Println(menu.Line(*format, s))
+		}
+		return nil
+	}
+
+	line := strings.Join(fs.Args(), " ")
+	if line == "" {
+		line, err = bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return errCancelled
+		}
+		line = strings.TrimRight(line, "\r\n")
+	}
+
+	snippet := menu.Find(*format, snippets, line)
+	if snippet == nil {
+		return fmt.Errorf("no snippet found for %q", line)
+	}
+
+	p := &menuPrompter{prompt: menuPrompts[*format]}
+	content, err := renderSnippet(snippet, c, p)
+	defer secrets.Wipe(content)
+	if p.err != nil {
+		return p.err
+	}
+	if err != nil {
+		return err
+	}
+
+	sent, err := sendOutput(content, snippet, c, true)
+	if sent || err != nil {
+		return err
+	}
+
+	time.Sleep(menuTypeDelay)
+
+	clearLastInsert()
+	err = typing.TypeSnippet(context.Background(), content, snippet.Copy, c.typingConfig(snippet, false))
+	if err != nil {
+		return err
+	}
+	if snippet.Copy != util.CopyModeClipboardOnly {
+		saveLastInsert(typing.NewInsert(content, snippet.Copy))
+	}
+	return nil
+}
+
+// launcherPrompt runs the launcher without any entries, so the user's input is taken as the value.
+func launcherPrompt(name string, args ...string) (string, bool, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader("")
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// fzf exits with 1 if the query matched none of the (zero) entries, but still prints the query.
+		if name == "fzf" && exitErr.ExitCode() == 1 {
+			return strings.SplitN(string(out), "\n", 2)[0], true, nil
+		}
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+
+	return strings.SplitN(string(out), "\n", 2)[0], true, nil
+}
+
+func ttyPassword(label string) (string, bool, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", false, err
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(tty, "%s> ", label)
+	pwd, err := terminal.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(tty)
+	if err != nil {
+		return "", false, err
+	}
+	return string(pwd), true, nil
+}
@@ -0,0 +1,63 @@
+package menu
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+
+	"github.com/sandro-h/snippet/util"
+)
+
+// formats format a snippet as a single line for a dmenu-like launcher.
+var formats = map[string]func(label string, content string) string{
+	"dmenu": func(label string, content string) string {
+		return label + ": " + content
+	},
+	"rofi": func(label string, content string) string {
+		return fmt.Sprintf("<b>%s</b> <span alpha=\"50%%\">%s</span>", html.EscapeString(label), html.EscapeString(content))
+	},
+	"fzf": func(label string, content string) string {
+		return label + "\t" + content
+	},
+}
+
+// Formats returns the names of the supported launchers.
+func Formats() []string {
+	var names []string
+	for n := range formats {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks that the format is supported.
+func Validate(format string) error {
+	if _, ok := formats[format]; !ok {
+		return fmt.Errorf("unknown format %s, must be one of: %s", format, strings.Join(Formats(), ", "))
+	}
+	return nil
+}
+
+// Line formats the snippet as a single line for the launcher. Line breaks in the content are shown as \n.
+func Line(format string, snippet *util.Snippet) string {
+	return formats[format](snippet.Label, strings.ReplaceAll(snippet.Content, "\n", "\\n"))
+}
+
+// Find finds the snippet for a line chosen in the launcher, as read from its output. As a fallback, the line
+// can also just be the snippet label.
+func Find(format string, snippets []*util.Snippet, line string) *util.Snippet {
+	line = strings.TrimRight(line, "\r\n")
+	for _, s := range snippets {
+		if Line(format, s) == line {
+			return s
+		}
+	}
+	for _, s := range snippets {
+		if s.Label == line {
+			return s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package menu
+
+import (
+	"testing"
+
+	"github.com/sandro-h/snippet/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func testSnippets() []*util.Snippet {
+	return []*util.Snippet{
+		{Label: "greeting", Content: "hello world"},
+		{Label: "signature", Content: "Regards\nBob"},
+		{Label: "html <b>", Content: "a & b"},
+		{Label: "hello world", Content: "greeting"},
+	}
+}
+
+func TestLine(t *testing.T) {
+	snippets := testSnippets()
+
+	assert.Equal(t, "greeting: hello world", Line("dmenu", snippets[0]))
+	assert.Equal(t, "greeting\thello world", Line("fzf", snippets[0]))
+	assert.Equal(t, `<b>greeting</b> <span alpha="50%">hello world</span>`, Line("rofi", snippets[0]))
+}
+
+func TestLineEscapesLineBreaks(t *testing.T) {
+	assert.Equal(t, `signature: Regards\nBob`, Line("dmenu", testSnippets()[1]))
+}
+
+func TestLineEscapesRofiMarkup(t *testing.T) {
+	assert.Equal(t, `<b>html &lt;b&gt;</b> <span alpha="50%">a &amp; b</span>`, Line("rofi", testSnippets()[2]))
+}
+
+func TestFind(t *testing.T) {
+	snippets := testSnippets()
+
+	for _, format := range Formats() {
+		for _, s := range snippets {
+			assert.Same(t, s, Find(format, snippets, Line(format, s)+"\n"), format)
+		}
+	}
+}
+
+func TestFindByLabel(t *testing.T) {
+	snippets := testSnippets()
+
+	assert.Same(t, snippets[1], Find("rofi", snippets, "signature\r\n"))
+	// A full line takes precedence over a label that happens to be the same.
+	assert.Same(t, snippets[0], Find("dmenu", snippets, "greeting: hello world"))
+	assert.Same(t, snippets[3], Find("dmenu", snippets, "hello world"))
+}
+
+func TestFindNothing(t *testing.T) {
+	assert.Nil(t, Find("fzf", testSnippets(), "greeting: hello world"))
+	assert.Nil(t, Find("fzf", testSnippets(), ""))
+}
+
+func TestValidate(t *testing.T) {
+	assert.Nil(t, Validate("rofi"))
+	assert.EqualError(t, Validate("wofi"), "unknown format wofi, must be one of: dmenu, fzf, rofi")
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"github.com/sandro-h/snippet/secrets"
+	"github.com/sandro-h/snippet/totp"
+	"github.com/sandro-h/snippet/typing"
+	"github.com/sandro-h/snippet/util"
+)
+
+// outputSnippet sends the content of the snippet to its output. Typing happens in the background
+// and is remembered for undo. outputSnippet takes ownership of the content and wipes it when it is done.
+func outputSnippet(state *appState, content []byte, snippet *util.Snippet, slow bool) {
+	c := currentConfig()
+	var err error
+	if snippet.TOTP != nil {
+		content, err = totpCode(snippet, content)
+		if err != nil {
+			log.Printf("Could not output snippet %s: %s", snippet.Label, err)
+			state.errorBanner.SetErrors("output", []string{fmt.Sprintf("Could not output snippet %s: %s", snippet.Label, err)})
+			return
+		}
+	}
+
+	sent, err := sendOutput(content, snippet, c, false)
+	if err != nil {
+		secrets.Wipe(content)
+		log.Printf("Could not output snippet %s: %s", snippet.Label, err)
+		state.errorBanner.SetErrors("output", []string{fmt.Sprintf("Could not output snippet %s: %s", snippet.Label, err)})
+		return
+	}
+	state.errorBanner.SetErrors("output", nil)
+	if sent {
+		secrets.Wipe(content)
+		return
+	}
+
+	clearLastInsert()
+	typing.TypeSnippetAsync(content, snippet.Copy, c.typingConfig(snippet, slow), func() {
+		if snippet.Copy == util.CopyModeClipboardOnly {
+			fyne.CurrentApp().SendNotification(fyne.NewNotification("Copied to clipboard", snippet.Label))
+			return
+		}
+		saveLastInsert(typing.NewInsert(content, snippet.Copy))
+	})
+}
+
+// sendOutput sends the content to the snippet's output, unless the snippet is typed. It returns
+// false if the content has to be typed instead. Output to stdout is only used from the CLI.
+func sendOutput(content []byte, snippet *util.Snippet, c *config, cli bool) (bool, error) {
+	switch snippet.Output {
+	case util.OutputFile:
+		file, err := outputFileFor(snippet, c)
+		if err != nil {
+			return true, err
+		}
+		return true, appendLine(file, content)
+	case util.OutputStdout:
+		if cli {
+			return true, writeWithTrailingNewline(os.Stdout, content)
+		}
+	case util.OutputPrimary:
+		return true, typing.WritePrimary(content)
+	}
+	return false, nil
+}
+
+// totpCode returns the current code of a TOTP snippet, generated from its decrypted seed. The seed is wiped.
+func totpCode(snippet *util.Snippet, seed []byte) ([]byte, error) {
+	defer secrets.Wipe(seed)
+	key, err := totp.DecodeSeed(seed)
+	if err != nil {
+		return nil, err
+	}
+	defer secrets.Wipe(key)
+	return totp.Code(key, time.Now(), *snippet.TOTP), nil
+}
+
+// outputFileFor returns the file the snippet appends to. Relative paths are relative to config.yml.
+func outputFileFor(snippet *util.Snippet, c *config) (string, error) {
+	if snippet.OutputFile != "" {
+		return util.ResolvePath(snippet.OutputFile, filepath.Dir(files.Config))
+	}
+	if c.outputFile == "" {
+		return "", fmt.Errorf("output: file needs an output_file in the snippet or in config.yml")
+	}
+	return c.outputFile, nil
+}
+
+func appendLine(file string, content []byte) error {
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	err = writeWithTrailingNewline(f, content)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeWithTrailingNewline writes the content and a newline if it does not end with one. The content is
+// not copied, so it can still be wiped afterwards.
+func writeWithTrailingNewline(w io.Writer, content []byte) error {
+	_, err := w.Write(content)
+	if err == nil && !bytes.HasSuffix(content, []byte("\n")) {
+		_, err = w.Write([]byte("\n"))
+	}
+	return err
+}
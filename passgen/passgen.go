@@ -0,0 +1,191 @@
+package passgen
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	lowerChars  = "abcdefghijklmnopqrstuvwxyz"
+	upperChars  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitChars  = "0123456789"
+	symbolChars = "!#$%&*+-./:;=?@^_~"
+	// ambiguousChars look alike in many fonts.
+	ambiguousChars = "Il1O0o"
+)
+
+// minLength is the shortest password that can be generated.
+const minLength = 4
+
+// Options describe the characters of a generated password.
+type Options struct {
+	Length  int
+	Lower   bool
+	Upper   bool
+	Digits  bool
+	Symbols bool
+	// ExcludeAmbiguous leaves out characters that look alike, like l, 1 and I.
+	ExcludeAmbiguous bool
+}
+
+// DefaultOptions generate passwords that are accepted by most services.
+var DefaultOptions = Options{Length: 20, Lower: true, Upper: true, Digits: true, Symbols: true}
+
+// Validate checks that passwords can be generated with the options.
+func (o Options) Validate() error {
+	if len(o.classes()) == 0 {
+		return fmt.Errorf("at least one of lower, upper, digits or symbols must be enabled")
+	}
+	if o.Length < minLength {
+		return fmt.Errorf("length must be at least %d, but was %d", minLength, o.Length)
+	}
+	return nil
+}
+
+// classes returns the enabled character classes.
+func (o Options) classes() []string {
+	var classes []string
+	for _, c := range []struct {
+		enabled bool
+		chars   string
+	}{{o.Lower, lowerChars}, {o.Upper, upperChars}, {o.Digits, digitChars}, {o.Symbols, symbolChars}} {
+		if !c.enabled {
+			continue
+		}
+		if o.ExcludeAmbiguous {
+			c.chars = strings.Map(func(r rune) rune {
+				if strings.ContainsRune(ambiguousChars, r) {
+					return -1
+				}
+				return r
+			}, c.chars)
+		}
+		classes = append(classes, c.chars)
+	}
+	return classes
+}
+
+// Password generates a random password. It contains at least one character of each enabled class.
+// The caller should wipe the password once it is not needed anymore.
+func Password(opts Options) ([]byte, error) {
+	err := opts.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	classes := opts.classes()
+	all := strings.Join(classes, "")
+	pwd := make([]byte, opts.Length)
+	for i := range pwd {
+		chars := all
+		if i < len(classes) {
+			chars = classes[i]
+		}
+		n, err := randIntn(len(chars))
+		if err != nil {
+			wipe(pwd)
+			return nil, err
+		}
+		pwd[i] = chars[n]
+	}
+
+	// Shuffle, so the characters of each class are not always at the start.
+	for i := len(pwd) - 1; i > 0; i-- {
+		j, err := randIntn(i + 1)
+		if err != nil {
+			wipe(pwd)
+			return nil, err
+		}
+		pwd[i], pwd[j] = pwd[j], pwd[i]
+	}
+	return pwd, nil
+}
+
+// PassphraseOptions describe a generated passphrase of random words.
+type PassphraseOptions struct {
+	Words     int
+	Separator string
+	// WordList are the words to choose from. If empty, a built-in list of 2048 english words is used.
+	WordList []string
+}
+
+// DefaultPassphraseOptions generate passphrases of 6 words from the built-in word list, about 66 bits of entropy.
+var DefaultPassphraseOptions = PassphraseOptions{Words: 6, Separator: "-"}
+
+// Validate checks that passphrases can be generated with the options.
+func (o PassphraseOptions) Validate() error {
+	if o.Words < 1 {
+		return fmt.Errorf("words must be at least 1, but was %d", o.Words)
+	}
+	if o.WordList != nil && len(o.WordList) < 2 {
+		return fmt.Errorf("word list must have at least 2 words, but has %d", len(o.WordList))
+	}
+	return nil
+}
+
+// Passphrase generates a passphrase of random words.
+// The caller should wipe the passphrase once it is not needed anymore.
+func Passphrase(opts PassphraseOptions) ([]byte, error) {
+	err := opts.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	list := opts.WordList
+	if len(list) == 0 {
+		list = wordList
+	}
+	// The words are appended to a slice of the final size, so no partial copies are left behind when it grows.
+	size := (opts.Words - 1) * len(opts.Separator)
+	indexes := make([]int, opts.Words)
+	for i := range indexes {
+		n, err := randIntn(len(list))
+		if err != nil {
+			return nil, err
+		}
+		indexes[i] = n
+		size += len(list[n])
+	}
+	phrase := make([]byte, 0, size)
+	for i, n := range indexes {
+		if i > 0 {
+			phrase = append(phrase, opts.Separator...)
+		}
+		phrase = append(phrase, list[n]...)
+	}
+	for i := range indexes {
+		indexes[i] = 0
+	}
+	return phrase, nil
+}
+
+// ParseWordList parses a word list with one word per line. Diceware lists like "11111	abacus" are supported:
+// only the last field of each line is used. Empty lines are ignored.
+func ParseWordList(content []byte) []string {
+	var words []string
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 {
+			words = append(words, fields[len(fields)-1])
+		}
+	}
+	return words
+}
+
+// randIntn returns a uniformly distributed random number in [0,n) from crypto/rand.
+func randIntn(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
+
+// wipe overwrites the data with zeros. passgen does not depend on the secrets package, so it has its own copy.
+func wipe(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+}
@@ -0,0 +1,87 @@
+package passgen
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordHasEveryClass(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		pwd, err := Password(DefaultOptions)
+
+		assert.NoError(t, err)
+		assert.Len(t, pwd, 20)
+		assert.True(t, bytes.ContainsAny(pwd, lowerChars), string(pwd))
+		assert.True(t, bytes.ContainsAny(pwd, upperChars), string(pwd))
+		assert.True(t, bytes.ContainsAny(pwd, digitChars), string(pwd))
+		assert.True(t, bytes.ContainsAny(pwd, symbolChars), string(pwd))
+	}
+}
+
+func TestPasswordOnlyEnabledClasses(t *testing.T) {
+	pwd, err := Password(Options{Length: 50, Digits: true})
+
+	assert.NoError(t, err)
+	assert.Len(t, pwd, 50)
+	assert.Empty(t, bytes.Trim(pwd, digitChars))
+}
+
+func TestPasswordExcludeAmbiguous(t *testing.T) {
+	opts := DefaultOptions
+	opts.Length = 200
+	opts.ExcludeAmbiguous = true
+
+	for i := 0; i < 20; i++ {
+		pwd, err := Password(opts)
+
+		assert.NoError(t, err)
+		assert.False(t, bytes.ContainsAny(pwd, ambiguousChars), string(pwd))
+	}
+}
+
+func TestPasswordInvalidOptions(t *testing.T) {
+	_, err := Password(Options{Length: 20})
+	assert.EqualError(t, err, "at least one of lower, upper, digits or symbols must be enabled")
+
+	_, err = Password(Options{Length: 3, Lower: true})
+	assert.EqualError(t, err, "length must be at least 4, but was 3")
+}
+
+func TestPassphrase(t *testing.T) {
+	phrase, err := Passphrase(DefaultPassphraseOptions)
+
+	assert.NoError(t, err)
+	words := strings.Split(string(phrase), "-")
+	assert.Len(t, words, 6)
+	for _, w := range words {
+		assert.Contains(t, wordList, w)
+	}
+}
+
+func TestPassphraseCustomWordList(t *testing.T) {
+	phrase, err := Passphrase(PassphraseOptions{Words: 4, Separator: " ", WordList: []string{"a", "b"}})
+
+	assert.NoError(t, err)
+	assert.Regexp(t, `^[ab] [ab] [ab] [ab]$`, string(phrase))
+}
+
+func TestPassphraseInvalidOptions(t *testing.T) {
+	_, err := Passphrase(PassphraseOptions{Words: 0})
+	assert.EqualError(t, err, "words must be at least 1, but was 0")
+
+	_, err = Passphrase(PassphraseOptions{Words: 3, WordList: []string{"a"}})
+	assert.EqualError(t, err, "word list must have at least 2 words, but has 1")
+}
+
+func TestParseWordList(t *testing.T) {
+	words := ParseWordList([]byte("apple\n\n  banana \r\n11111\tcherry\n"))
+
+	assert.Equal(t, []string{"apple", "banana", "cherry"}, words)
+}
+
+func TestWordList(t *testing.T) {
+	assert.Len(t, wordList, 2048)
+}
@@ -0,0 +1,133 @@
+package paths
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// ConfigEnv is the environment variable to override the config file.
+	ConfigEnv = "SNIPPET_CONFIG"
+	// SnippetsEnv is the environment variable to override the snippets files, separated by
+	// the OS's path list separator (':' on Linux and macOS, ';' on Windows).
+	SnippetsEnv = "SNIPPET_FILES"
+
+	configFileName   = "config.yml"
+	snippetsFileName = "snippets.yml"
+)
+
+// Files are the locations of the files used by snippet.
+type Files struct {
+	// Dir is the directory of the default files. It is the binary's directory in portable mode,
+	// otherwise $XDG_CONFIG_HOME/snippet.
+	Dir      string
+	Config   string
+	Snippets []string
+	// StateDir is the directory for state that snippet remembers between runs, like the active profile.
+	// It is the binary's directory in portable mode, otherwise $XDG_STATE_HOME/snippet.
+	StateDir string
+}
+
+// Overrides are file locations given explicitly, e.g. with command-line flags. Empty values are ignored.
+type Overrides struct {
+	Config   string
+	Snippets []string
+}
+
+// Resolve finds the files to use. For each file, the first of these applies:
+//
+//  1. the overrides
+//  2. the SNIPPET_CONFIG and SNIPPET_FILES environment variables
+//  3. portable mode: the binary's directory, if it contains a config.yml or snippets.yml
+//  4. $XDG_CONFIG_HOME/snippet, or ~/.config/snippet if XDG_CONFIG_HOME is not set
+//
+// getenv is used to read environment variables, usually os.Getenv.
+func Resolve(overrides Overrides, getenv func(string) string, appDir string) (*Files, error) {
+	dir, stateDir, err := defaultDirs(getenv, appDir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := &Files{
+		Dir:      dir,
+		Config:   filepath.Join(dir, configFileName),
+		Snippets: []string{filepath.Join(dir, snippetsFileName)},
+		StateDir: stateDir,
+	}
+
+	if overrides.Config != "" {
+		files.Config = overrides.Config
+	} else if env := getenv(ConfigEnv); env != "" {
+		files.Config = env
+	}
+
+	if len(overrides.Snippets) > 0 {
+		files.Snippets = overrides.Snippets
+	} else if env := splitList(getenv(SnippetsEnv)); len(env) > 0 {
+		files.Snippets = env
+	}
+
+	return files, nil
+}
+
+// IsDefault returns whether the snippets files are the default snippets file, i.e. were not overridden.
+func (f *Files) IsDefault() bool {
+	return len(f.Snippets) == 1 && f.Snippets[0] == filepath.Join(f.Dir, snippetsFileName)
+}
+
+// defaultDirs returns the default config and state directories.
+func defaultDirs(getenv func(string) string, appDir string) (string, string, error) {
+	if isPortable(appDir) {
+		return appDir, appDir, nil
+	}
+
+	configDir, err := xdgDir(getenv, "XDG_CONFIG_HOME", ".config")
+	if err != nil {
+		return "", "", err
+	}
+	stateDir, err := xdgDir(getenv, "XDG_STATE_HOME", filepath.Join(".local", "state"))
+	if err != nil {
+		return "", "", err
+	}
+	return configDir, stateDir, nil
+}
+
+func xdgDir(getenv func(string) string, env string, homeFallback string) (string, error) {
+	if xdg := getenv(env); filepath.IsAbs(xdg) {
+		return filepath.Join(xdg, "snippet"), nil
+	}
+	if home := getenv("HOME"); home != "" {
+		return filepath.Join(home, homeFallback, "snippet"), nil
+	}
+
+	// No HOME, e.g. on Windows.
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "snippet"), nil
+}
+
+func isPortable(appDir string) bool {
+	// Without an app dir, the files would be looked up in the working directory.
+	if appDir == "" {
+		return false
+	}
+	for _, f := range []string{configFileName, snippetsFileName} {
+		if _, err := os.Stat(filepath.Join(appDir, f)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(list string) []string {
+	var parts []string
+	for _, p := range strings.Split(list, string(os.PathListSeparator)) {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return parts
+}
@@ -0,0 +1,92 @@
+package paths
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeEnv(vars map[string]string) func(string) string {
+	return func(key string) string {
+		return vars[key]
+	}
+}
+
+func TestResolveXDG(t *testing.T) {
+	env := fakeEnv(map[string]string{"XDG_CONFIG_HOME": "/xdg", "XDG_STATE_HOME": "/xdg-state", "HOME": "/home/me"})
+	files, err := Resolve(Overrides{}, env, t.TempDir())
+
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join("/xdg", "snippet"), files.Dir)
+	assert.Equal(t, filepath.Join("/xdg-state", "snippet"), files.StateDir)
+	assert.Equal(t, filepath.Join("/xdg", "snippet", "config.yml"), files.Config)
+	assert.Equal(t, []string{filepath.Join("/xdg", "snippet", "snippets.yml")}, files.Snippets)
+	assert.True(t, files.IsDefault())
+}
+
+func TestResolveHomeFallback(t *testing.T) {
+	files, err := Resolve(Overrides{}, fakeEnv(map[string]string{"HOME": "/home/me"}), t.TempDir())
+
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join("/home/me", ".config", "snippet", "config.yml"), files.Config)
+	assert.Equal(t, filepath.Join("/home/me", ".local", "state", "snippet"), files.StateDir)
+}
+
+func TestResolvePortable(t *testing.T) {
+	appDir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(appDir, "snippets.yml"), nil, 0644))
+
+	files, err := Resolve(Overrides{}, fakeEnv(map[string]string{"XDG_CONFIG_HOME": "/xdg"}), appDir)
+
+	assert.Nil(t, err)
+	assert.Equal(t, appDir, files.Dir)
+	assert.Equal(t, appDir, files.StateDir)
+	assert.Equal(t, filepath.Join(appDir, "config.yml"), files.Config)
+	assert.Equal(t, []string{filepath.Join(appDir, "snippets.yml")}, files.Snippets)
+}
+
+func TestResolveNotPortableWithoutAppDir(t *testing.T) {
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	cwd := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(cwd, "snippets.yml"), nil, 0644))
+	assert.Nil(t, os.Chdir(cwd))
+	defer os.Chdir(wd)
+
+	files, err := Resolve(Overrides{}, fakeEnv(map[string]string{"XDG_CONFIG_HOME": "/xdg"}), "")
+
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join("/xdg", "snippet"), files.Dir)
+}
+
+func TestResolveEnv(t *testing.T) {
+	env := fakeEnv(map[string]string{
+		"XDG_CONFIG_HOME": "/xdg",
+		ConfigEnv:         "/shared/config.yml",
+		SnippetsEnv:       strings.Join([]string{"/shared/team.yml", "/home/me/mine.yml"}, string(os.PathListSeparator)),
+	})
+
+	files, err := Resolve(Overrides{}, env, t.TempDir())
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/shared/config.yml", files.Config)
+	assert.Equal(t, []string{"/shared/team.yml", "/home/me/mine.yml"}, files.Snippets)
+	assert.False(t, files.IsDefault())
+}
+
+func TestResolveOverridesWinOverEnv(t *testing.T) {
+	env := fakeEnv(map[string]string{
+		"XDG_CONFIG_HOME": "/xdg",
+		ConfigEnv:         "/shared/config.yml",
+		SnippetsEnv:       "/shared/team.yml",
+	})
+
+	files, err := Resolve(Overrides{Config: "my-config.yml", Snippets: []string{"a.yml", "b.yml"}}, env, t.TempDir())
+
+	assert.Nil(t, err)
+	assert.Equal(t, "my-config.yml", files.Config)
+	assert.Equal(t, []string{"a.yml", "b.yml"}, files.Snippets)
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sandro-h/snippet/util"
+)
+
+// profileEnv is the environment variable to override the active profile, like --profile.
+const profileEnv = "SNIPPET_PROFILE"
+
+// profile is a named set of snippets files and settings, e.g. for work and home or for different customers.
+// Only the snippets of the active profile are loaded.
+type profile struct {
+	name          string
+	snippetsFiles []string
+	variables     map[string]string
+	// secretTTL overrides the global secret_ttl if not 0.
+	secretTTL time.Duration
+	// activateHotkeys and editorHotkeys override the global hotkeys if not nil.
+	activateHotkeys []string
+	editorHotkeys   []string
+	// switchHotkeys switch to the profile, regardless of the active profile.
+	switchHotkeys []string
+}
+
+type rawProfile struct {
+	Snippets        []string          `yaml:"snippets"`
+	Variables       map[string]string `yaml:"variables"`
+	SecretTTL       string            `yaml:"secret_ttl"`
+	ActivateHotkeys []string          `yaml:"activate_hotkeys"`
+	EditorHotkeys   []string          `yaml:"editor_hotkeys"`
+	SwitchHotkeys   []string          `yaml:"switch_hotkeys"`
+}
+
+// toProfile converts the profile from config.yml. Relative snippets files are relative to configDir.
+func (raw rawProfile) toProfile(name string, configDir string) (*profile, error) {
+	if strings.ContainsAny(name, " \t\n") {
+		return nil, errors.New("name must not contain spaces")
+	}
+
+	p := &profile{
+		name:            name,
+		variables:       raw.Variables,
+		activateHotkeys: raw.ActivateHotkeys,
+		editorHotkeys:   raw.EditorHotkeys,
+		switchHotkeys:   raw.SwitchHotkeys,
+	}
+
+	for _, f := range raw.Snippets {
+		f, err := util.ResolvePath(f, configDir)
+		if err != nil {
+			return nil, err
+		}
+		p.snippetsFiles = append(p.snippetsFiles, f)
+	}
+
+	if raw.SecretTTL != "" {
+		var err error
+		p.secretTTL, err = parseSecretTTL(raw.SecretTTL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// withProfile returns a copy of the config with the settings of the profile applied.
+// An empty name returns the config without a profile.
+func (c *config) withProfile(name string) (*config, error) {
+	if name == "" {
+		return c, nil
+	}
+
+	p, ok := c.profiles[name]
+	if !ok {
+		if len(c.profiles) == 0 {
+			return nil, fmt.Errorf("unknown profile %s, there are no profiles in config.yml", name)
+		}
+		return nil, fmt.Errorf("unknown profile %s, must be one of: %s", name, strings.Join(c.profileNames(), ", "))
+	}
+
+	withProfile := *c
+	withProfile.profile = p
+	withProfile.variables = p.variables
+	if p.secretTTL != 0 {
+		withProfile.secretTTL = p.secretTTL
+	}
+	if p.activateHotkeys != nil {
+		withProfile.activateHotkeys = p.activateHotkeys
+	}
+	if p.editorHotkeys != nil {
+		withProfile.editorHotkeys = p.editorHotkeys
+	}
+	return &withProfile, nil
+}
+
+// profileName returns the name of the active profile, or an empty string if no profile is active.
+func (c *config) profileName() string {
+	if c.profile == nil {
+		return ""
+	}
+	return c.profile.name
+}
+
+func (c *config) profileNames() []string {
+	var names []string
+	for n := range c.profiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// profileOverride returns the profile given with --profile or SNIPPET_PROFILE, if any.
+func profileOverride() string {
+	if *profileFlag != "" {
+		return *profileFlag
+	}
+	return os.Getenv(profileEnv)
+}
+
+// activeProfileName returns the profile to use: the override if given, otherwise the profile
+// that was last switched to, otherwise the config's default profile.
+func activeProfileName(c *config, override string) string {
+	if override != "" {
+		return override
+	}
+
+	bytes, err := os.ReadFile(activeProfileFile())
+	if err == nil {
+		name := strings.TrimSpace(string(bytes))
+		if _, ok := c.profiles[name]; ok {
+			return name
+		} else if name != "" {
+			log.Printf("Active profile %s does not exist anymore, using default profile", name)
+		}
+	}
+
+	return c.defaultProfile
+}
+
+// activeProfileFile is the file that remembers the active profile. The snippet widget watches it,
+// so switching the profile from the CLI also switches the widget's profile.
+func activeProfileFile() string {
+	return filepath.Join(files.StateDir, "profile")
+}
+
+func setActiveProfile(name string) error {
+	err := os.MkdirAll(files.StateDir, 0700)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(activeProfileFile(), []byte(name+"\n"), 0600)
+}
+
+// switchProfile makes the profile the active profile. It replaces the --profile override of the widget.
+// The snippet widget picks up the change through its file watcher, which sees the write even if the name
+// in the file stays the same.
+func switchProfile(state *appState, name string) {
+	_, err := currentConfig().withProfile(name)
+	if err == nil {
+		cfgLock.Lock()
+		state.profileOverride = ""
+		cfgLock.Unlock()
+		err = setActiveProfile(name)
+	}
+	if err != nil {
+		log.Println("error switching profile:", err)
+		state.errorBanner.SetErrors("profile", []string{err.Error()})
+		return
+	}
+	state.errorBanner.SetErrors("profile", nil)
+}
+
+// onProfileChanged locks all secrets, so no secret unlocked in one profile can be typed in another.
+func onProfileChanged(state *appState, c *config) {
+	state.store.EvictAllSecrets()
+
+	if c.profile == nil {
+		log.Println("No profile active")
+		state.search.Entry.SetPlaceHolder("")
+	} else {
+		log.Println("Switched to profile", c.profile.name)
+		state.search.Entry.SetPlaceHolder("Profile: " + c.profile.name)
+	}
+}
+
+// snippetsFilesFor returns the snippets files to load for the config. Snippets files given explicitly
+// with --snippets or SNIPPET_FILES take precedence over the profile's snippets files.
+func snippetsFilesFor(c *config) []string {
+	if files.IsDefault() && c.profile != nil && len(c.profile.snippetsFiles) > 0 {
+		return c.profile.snippetsFiles
+	}
+	return files.Snippets
+}
+
+// allSnippetsFiles returns the snippets files of all profiles, not only the active one, e.g. to migrate all secrets.
+// Files that are used by several profiles are only returned once.
+func allSnippetsFiles(c *config) []string {
+	if !files.IsDefault() {
+		return files.Snippets
+	}
+	var all []string
+	seen := make(map[string]bool)
+	add := func(snippetsFiles []string) {
+		for _, f := range snippetsFiles {
+			if !seen[f] {
+				seen[f] = true
+				all = append(all, f)
+			}
+		}
+	}
+	add(snippetsFilesFor(c))
+	for _, name := range c.profileNames() {
+		if p := c.profiles[name]; len(p.snippetsFiles) > 0 {
+			add(p.snippetsFiles)
+		} else {
+			add(files.Snippets)
+		}
+	}
+	return all
+}
+
+// defaultSnippetsFile returns the snippets file that new snippets are added to, the first snippets file of the config.
+func defaultSnippetsFile(c *config) (string, error) {
+	snippetsFiles := snippetsFilesFor(c)
+	if len(snippetsFiles) == 0 {
+		return "", errors.New("there are no snippets files, use --file")
+	}
+	return snippetsFiles[0], nil
+}
+
+func watchedFiles(state *appState) []string {
+	return append([]string{files.Config, activeProfileFile()}, currentSnippetsFiles(state)...)
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// runSearchCommand runs a command entered in the search widget, like "/profile work".
+func runSearchCommand(state *appState, name string, args []string) {
+	switch name {
+	case "profile":
+		if len(args) != 1 {
+			state.errorBanner.SetErrors("profile", []string{"Usage: /profile <name>, profiles: " + strings.Join(currentConfig().profileNames(), ", ")})
+			return
+		}
+		switchProfile(state, args[0])
+	case "lock":
+		lockAll(state, "/lock")
+	case "generate":
+		runGenerateCommand(state, args)
+	default:
+		state.errorBanner.SetErrors("profile", []string{"Unknown command /" + name})
+	}
+}
+
+// runProfile prints the profiles, or switches to the given profile.
+func runProfile(args []string) error {
+	c, err := loadAppConfig()
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		if len(c.profiles) == 0 {
+			return fmt.Errorf("there are no profiles in %s", files.Config)
+		}
+		active := activeProfileName(c, profileOverride())
+		for _, n := range c.profileNames() {
+			if n == active {
+				fmt.Println("* " + n)
+			} else {
+				fmt.Println("  " + n)
+			}
+		}
+		return nil
+	}
+
+	_, err = c.withProfile(args[0])
+	if err != nil {
+		return err
+	}
+	return setActiveProfile(args[0])
+}
+
+func completeProfiles(args []string) []string {
+	if len(args) > 1 {
+		return nil
+	}
+	c, err := loadAppConfig()
+	if err != nil {
+		return nil
+	}
+	return c.profileNames()
+}
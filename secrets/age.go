@@ -0,0 +1,104 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+const ageMagic = "age-encryption.org/v1"
+
+// AgeEncrypt encrypts the secret for all recipients. The result is base64-encoded, so it fits on a single line.
+func AgeEncrypt(secret []byte, recipients []age.Recipient) (string, error) {
+	if len(recipients) == 0 {
+		return "", fmt.Errorf("no recipients")
+	}
+
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipients...)
+	if err != nil {
+		return "", err
+	}
+	_, err = w.Write(secret)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// AgeDecrypt decrypts a base64-encoded or ASCII-armored age payload with one of the identities.
+func AgeDecrypt(payload string, identities []age.Identity) ([]byte, error) {
+	payload = strings.TrimSpace(payload)
+	var src io.Reader
+	if strings.HasPrefix(payload, armor.Header) {
+		src = armor.NewReader(strings.NewReader(payload))
+	} else {
+		raw, err := base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			return nil, fmt.Errorf("age secret is neither base64 nor ASCII-armored: %w", err)
+		}
+		src = bytes.NewReader(raw)
+	}
+
+	r, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// IsAgeIdentityEncrypted returns whether the age identity file is itself encrypted with a passphrase,
+// like the files created with "age --passphrase".
+func IsAgeIdentityEncrypted(identityFile []byte) bool {
+	content := strings.TrimSpace(string(identityFile))
+	return strings.HasPrefix(content, armor.Header) || strings.HasPrefix(content, ageMagic)
+}
+
+// ParseAgeIdentities parses an age identity file. If the file is encrypted, it is decrypted with the passphrase first.
+func ParseAgeIdentities(identityFile []byte, passphrase string) ([]age.Identity, error) {
+	if !IsAgeIdentityEncrypted(identityFile) {
+		return age.ParseIdentities(bytes.NewReader(identityFile))
+	}
+
+	scrypt, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	var src io.Reader = bytes.NewReader(identityFile)
+	if strings.HasPrefix(strings.TrimSpace(string(identityFile)), armor.Header) {
+		src = armor.NewReader(bytes.NewReader(bytes.TrimSpace(identityFile)))
+	}
+	r, err := age.Decrypt(src, scrypt)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt age identity: %w", err)
+	}
+	return age.ParseIdentities(r)
+}
+
+// ParseAgeRecipients parses the recipients given as public keys and in recipients files.
+func ParseAgeRecipients(keys []string, recipientsFiles [][]byte) ([]age.Recipient, error) {
+	var recipients []age.Recipient
+	for _, k := range keys {
+		r, err := age.ParseX25519Recipient(k)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, r)
+	}
+	for _, f := range recipientsFiles {
+		rs, err := age.ParseRecipients(bytes.NewReader(f))
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, rs...)
+	}
+	return recipients, nil
+}
@@ -0,0 +1,79 @@
+package secrets
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgeEncryptDecrypt(t *testing.T) {
+	alice, _ := age.GenerateX25519Identity()
+	bob, _ := age.GenerateX25519Identity()
+	recipients, err := ParseAgeRecipients([]string{alice.Recipient().String()}, [][]byte{[]byte("# team\n" + bob.Recipient().String() + "\n")})
+	assert.Nil(t, err)
+
+	payload, err := AgeEncrypt([]byte("hello world"), recipients)
+	assert.Nil(t, err)
+	assert.NotContains(t, payload, "\n")
+
+	for _, id := range []age.Identity{alice, bob} {
+		dec, err := AgeDecrypt(payload, []age.Identity{id})
+		assert.Nil(t, err)
+		assert.Equal(t, "hello world", string(dec))
+	}
+
+	eve, _ := age.GenerateX25519Identity()
+	_, err = AgeDecrypt(payload, []age.Identity{eve})
+	assert.Error(t, err)
+}
+
+func TestAgeDecryptArmored(t *testing.T) {
+	id, _ := age.GenerateX25519Identity()
+	var buf bytes.Buffer
+	a := armor.NewWriter(&buf)
+	w, _ := age.Encrypt(a, id.Recipient())
+	w.Write([]byte("hello world"))
+	w.Close()
+	a.Close()
+
+	dec, err := AgeDecrypt(buf.String(), []age.Identity{id})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "hello world", string(dec))
+}
+
+func TestParseAgeIdentities(t *testing.T) {
+	id, _ := age.GenerateX25519Identity()
+	file := []byte("# created: today\n" + id.String() + "\n")
+
+	assert.False(t, IsAgeIdentityEncrypted(file))
+	ids, err := ParseAgeIdentities(file, "")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []age.Identity{id}, ids)
+}
+
+func TestParseAgeIdentitiesWithPassphrase(t *testing.T) {
+	id, _ := age.GenerateX25519Identity()
+	scrypt, _ := age.NewScryptRecipient("passphrase")
+	scrypt.SetWorkFactor(10)
+	var buf bytes.Buffer
+	a := armor.NewWriter(&buf)
+	w, _ := age.Encrypt(a, scrypt)
+	w.Write([]byte(id.String() + "\n"))
+	w.Close()
+	a.Close()
+	file := buf.Bytes()
+
+	assert.True(t, IsAgeIdentityEncrypted(file))
+	ids, err := ParseAgeIdentities(file, "passphrase")
+	assert.Nil(t, err)
+	assert.Equal(t, []age.Identity{id}, ids)
+
+	_, err = ParseAgeIdentities(file, "wrong")
+	assert.True(t, strings.HasPrefix(err.Error(), "could not decrypt age identity"))
+}
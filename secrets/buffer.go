@@ -0,0 +1,48 @@
+package secrets
+
+// Buffer holds a decrypted secret in memory that is locked into RAM, so it is not written to swap, and that
+// is excluded from core dumps where the OS supports it. Destroy wipes the memory again.
+// Go strings cannot be wiped, so decrypted secrets should only be kept in a Buffer or in byte slices that
+// are wiped after use.
+type Buffer struct {
+	mem []byte
+	// mapped is whether mem was mapped by allocLocked, rather than allocated on the Go heap.
+	mapped bool
+}
+
+// NewBuffer copies the data into a new locked buffer. The caller should Wipe the data afterwards.
+func NewBuffer(data []byte) *Buffer {
+	b := &Buffer{}
+	b.mem, b.mapped = allocLocked(len(data))
+	copy(b.mem, data)
+	return b
+}
+
+// Bytes returns the content of the buffer, or nil if it was destroyed. The returned slice must not be
+// used after Destroy.
+func (b *Buffer) Bytes() []byte {
+	if b == nil {
+		return nil
+	}
+	return b.mem
+}
+
+// Destroy wipes the buffer and releases its memory. It can be called more than once.
+func (b *Buffer) Destroy() {
+	if b == nil || b.mem == nil {
+		return
+	}
+	Wipe(b.mem)
+	if b.mapped {
+		freeLocked(b.mem)
+	}
+	b.mem = nil
+	b.mapped = false
+}
+
+// Wipe overwrites the data with zeros.
+func Wipe(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+}
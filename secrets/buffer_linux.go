@@ -0,0 +1,38 @@
+package secrets
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// mmap and munmap are variables, so tests can simulate failures.
+var (
+	mmap   = unix.Mmap
+	munmap = unix.Munmap
+)
+
+// allocLocked maps memory outside of the Go heap, so the garbage collector never copies it, locks it
+// into RAM and excludes it from core dumps. If that is not possible, it falls back to memory on the Go heap
+// that is only wiped. It returns whether the memory was mapped and must be released with freeLocked.
+func allocLocked(size int) ([]byte, bool) {
+	if size == 0 {
+		return []byte{}, false
+	}
+
+	pageSize := unix.Getpagesize()
+	mem, err := mmap(-1, 0, (size+pageSize-1)/pageSize*pageSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return make([]byte, size), false
+	}
+	// Both are best-effort, e.g. Mlock fails if RLIMIT_MEMLOCK is exceeded. The memory is still wiped.
+	unix.Mlock(mem)
+	unix.Madvise(mem, unix.MADV_DONTDUMP)
+	return mem[:size], true
+}
+
+// freeLocked releases memory that was mapped by allocLocked. It must not be called for the fallback memory,
+// which is freed by the garbage collector.
+func freeLocked(mem []byte) {
+	mem = mem[:cap(mem)]
+	unix.Munlock(mem)
+	munmap(mem)
+}
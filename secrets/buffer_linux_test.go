@@ -0,0 +1,43 @@
+package secrets
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBufferMapped(t *testing.T) {
+	b := NewBuffer([]byte("hunter2"))
+
+	assert.True(t, b.mapped)
+	assert.Equal(t, []byte("hunter2"), b.Bytes())
+	b.Destroy()
+	assert.Nil(t, b.Bytes())
+}
+
+func TestBufferFallbackIsNotUnmapped(t *testing.T) {
+	origMmap, origMunmap := mmap, munmap
+	defer func() {
+		mmap, munmap = origMmap, origMunmap
+	}()
+	mmap = func(fd int, offset int64, length int, prot int, flags int) ([]byte, error) {
+		return nil, errors.New("out of memory")
+	}
+	unmapped := false
+	munmap = func(b []byte) error {
+		unmapped = true
+		return nil
+	}
+
+	b := NewBuffer([]byte("hunter2"))
+	assert.False(t, b.mapped)
+	assert.Equal(t, []byte("hunter2"), b.Bytes())
+	mem := b.Bytes()
+
+	b.Destroy()
+
+	assert.False(t, unmapped)
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0}, mem)
+	assert.Nil(t, b.Bytes())
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package secrets
+
+// allocLocked returns regular memory, which is only wiped but not locked on this OS.
+func allocLocked(size int) ([]byte, bool) {
+	return make([]byte, size), false
+}
+
+func freeLocked(mem []byte) {}
@@ -0,0 +1,37 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuffer(t *testing.T) {
+	data := []byte("hunter2")
+
+	b := NewBuffer(data)
+	Wipe(data)
+
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0}, data)
+	assert.Equal(t, []byte("hunter2"), b.Bytes())
+
+	b.Destroy()
+	assert.Nil(t, b.Bytes())
+	b.Destroy()
+}
+
+func TestBufferEmpty(t *testing.T) {
+	b := NewBuffer(nil)
+
+	assert.NotNil(t, b.Bytes())
+	assert.Empty(t, b.Bytes())
+	b.Destroy()
+	assert.Nil(t, b.Bytes())
+}
+
+func TestBufferNil(t *testing.T) {
+	var b *Buffer
+
+	assert.Nil(t, b.Bytes())
+	b.Destroy()
+}
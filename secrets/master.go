@@ -0,0 +1,125 @@
+package secrets
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MasterPrefix marks secrets that are encrypted with the master key instead of their own password.
+const MasterPrefix = "MASTER:"
+
+// ErrWrongPassword is returned when the master password does not match the master key.
+var ErrWrongPassword = errors.New("wrong master password")
+
+const keyCheckInfo = "snippet master key check"
+
+// Secrets encrypted with the master key use the key directly with AES-GCM, since it is random and needs no KDF.
+const masterV2Prefix = "v2:aes-gcm:"
+
+// MasterKey is a random key that is encrypted with the master password. Unlocking it once with the
+// master password gives access to all secrets that are encrypted with it.
+type MasterKey struct {
+	// Key is the random key, encrypted with the master password.
+	Key string `yaml:"key"`
+	// Check is the key check value, which verifies that the master password decrypted the right key.
+	Check string `yaml:"check"`
+}
+
+// NewMasterKey creates a new random master key and encrypts it with the master password.
+func NewMasterKey(password string, params KDFParams) (*MasterKey, error) {
+	raw := make([]byte, 32)
+	defer Wipe(raw)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return nil, err
+	}
+	key := make([]byte, hex.EncodedLen(len(raw)))
+	defer Wipe(key)
+	hex.Encode(key, raw)
+
+	enc, err := EncryptWithParams(key, []byte(password), params)
+	if err != nil {
+		return nil, err
+	}
+	return &MasterKey{Key: enc, Check: keyCheck(key)}, nil
+}
+
+// Unlock decrypts the key with the master password and verifies it against the key check value.
+// The caller should Wipe the key once it is not needed anymore, or keep it in a Buffer.
+func (m *MasterKey) Unlock(password string) ([]byte, error) {
+	key, err := Decrypt(m.Key, password)
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+	if !hmac.Equal([]byte(keyCheck(key)), []byte(m.Check)) {
+		Wipe(key)
+		return nil, ErrWrongPassword
+	}
+	return key, nil
+}
+
+// IsMasterSecret returns whether the secret is encrypted with the master key.
+func IsMasterSecret(cipher string) bool {
+	return strings.HasPrefix(cipher, MasterPrefix)
+}
+
+// EncryptWithMasterKey encrypts the secret with the unlocked master key.
+func EncryptWithMasterKey(secret []byte, key []byte) (string, error) {
+	raw, err := decodeMasterKey(key)
+	if err != nil {
+		return "", err
+	}
+	defer Wipe(raw)
+	sealed, err := sealGCM(secret, raw)
+	if err != nil {
+		return "", err
+	}
+	return MasterPrefix + masterV2Prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptWithMasterKey decrypts a secret that was encrypted with the unlocked master key. Secrets in the
+// Ansible Vault format, which used the master key as password, are also supported.
+func DecryptWithMasterKey(cipher string, key []byte) ([]byte, error) {
+	if !IsMasterSecret(cipher) {
+		return nil, fmt.Errorf("Cipher is missing %s prefix", MasterPrefix)
+	}
+	cipher = cipher[len(MasterPrefix):]
+	if !strings.HasPrefix(cipher, masterV2Prefix) {
+		// The Ansible Vault library only takes the password as a string. Such secrets should be migrated anyway.
+		return Decrypt(cipher, string(key))
+	}
+
+	raw, err := decodeMasterKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer Wipe(raw)
+	sealed, err := base64.StdEncoding.DecodeString(cipher[len(masterV2Prefix):])
+	if err != nil {
+		return nil, err
+	}
+	return openGCM(sealed, raw)
+}
+
+// decodeMasterKey decodes the hex encoded master key into the raw AES key. The caller should Wipe it after use.
+func decodeMasterKey(key []byte) ([]byte, error) {
+	raw := make([]byte, hex.DecodedLen(len(key)))
+	_, err := hex.Decode(raw, key)
+	if err != nil {
+		Wipe(raw)
+		return nil, fmt.Errorf("invalid master key: %w", err)
+	}
+	return raw, nil
+}
+
+func keyCheck(key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(keyCheckInfo))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
@@ -0,0 +1,55 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMasterKey(t *testing.T) {
+	master, err := NewMasterKey("master password", testKDFParams)
+	assert.Nil(t, err)
+
+	key, err := master.Unlock("master password")
+	assert.Nil(t, err)
+	assert.Len(t, key, 64)
+
+	cipher, err := EncryptWithMasterKey([]byte("hello world"), key)
+	assert.Nil(t, err)
+	assert.True(t, IsMasterSecret(cipher))
+	assert.False(t, IsMasterSecret(cipher[len(MasterPrefix):]))
+
+	dec, err := DecryptWithMasterKey(cipher, key)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello world", string(dec))
+}
+
+func TestMasterKeyWrongPassword(t *testing.T) {
+	master, _ := NewMasterKey("master password", testKDFParams)
+
+	_, err := master.Unlock("wrong")
+
+	assert.Equal(t, ErrWrongPassword, err)
+}
+
+func TestMasterKeyCheckMismatch(t *testing.T) {
+	master, _ := NewMasterKey("master password", testKDFParams)
+	other, _ := NewMasterKey("master password", testKDFParams)
+	master.Check = other.Check
+
+	_, err := master.Unlock("master password")
+
+	assert.Equal(t, ErrWrongPassword, err)
+}
+
+func TestDecryptLegacyMasterSecret(t *testing.T) {
+	master, _ := NewMasterKey("master password", testKDFParams)
+	key, _ := master.Unlock("master password")
+	legacy, _ := ansibleVaultEncrypt("hello world", string(key))
+
+	dec, err := DecryptWithMasterKey(MasterPrefix+legacy, key)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "hello world", string(dec))
+	assert.True(t, IsLegacy(MasterPrefix+legacy))
+}
@@ -1,24 +1,173 @@
 package secrets
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"strings"
 
 	vault "github.com/sosedoff/ansible-vault-go"
+	"golang.org/x/crypto/argon2"
 )
 
-// Encrypt encrypts the secret using the given password.
-func Encrypt(secret string, password string) (string, error) {
-	return ansibleVaultEncrypt(secret, password)
+const (
+	// LegacyPrefix marks secrets in the Ansible Vault format.
+	LegacyPrefix = "AES256:"
+	// V2Prefix marks secrets that are encrypted with AES-GCM and a key derived with Argon2id.
+	V2Prefix = "v2:argon2id:"
+)
+
+// KDFParams are the cost parameters of Argon2id, which derives the key from the password.
+// Higher values make guessing the password more expensive, but also slow down decrypting.
+type KDFParams struct {
+	// Memory is the memory in KiB.
+	Memory  uint32 `yaml:"memory"`
+	Time    uint32 `yaml:"time"`
+	Threads uint8  `yaml:"threads"`
+}
+
+// DefaultKDFParams are the parameters recommended by RFC 9106 for memory-constrained environments.
+var DefaultKDFParams = KDFParams{Memory: 64 * 1024, Time: 3, Threads: 4}
+
+const saltLen = 16
+
+// Encrypt encrypts the secret using the given password, with the default KDF parameters.
+func Encrypt(secret []byte, password []byte) (string, error) {
+	return EncryptWithParams(secret, password, DefaultKDFParams)
 }
 
-// Decrypt decrypts the cipher using the given master password.
-func Decrypt(cipher string, password string) (string, error) {
+// EncryptWithParams encrypts the secret using the given password in the v2 format:
+// v2:argon2id:m=<memory>,t=<time>,p=<threads>:<base64 salt>:<base64 nonce and ciphertext>
+// The secret and the password are taken as byte slices, so the caller can wipe them afterwards.
+func EncryptWithParams(secret []byte, password []byte, params KDFParams) (string, error) {
+	if err := params.Validate(); err != nil {
+		return "", err
+	}
+
+	salt := make([]byte, saltLen)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := deriveKey(password, salt, params)
+	defer Wipe(key)
+	sealed, err := sealGCM(secret, key)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%sm=%d,t=%d,p=%d:%s:%s", V2Prefix, params.Memory, params.Time, params.Threads,
+		base64.StdEncoding.EncodeToString(salt), base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// Decrypt decrypts the cipher using the given master password. Both the v2 and the Ansible Vault format are supported.
+// The caller should Wipe the result once it is not needed anymore.
+func Decrypt(cipher string, password string) ([]byte, error) {
+	if strings.HasPrefix(cipher, V2Prefix) {
+		return decryptV2(cipher, password)
+	}
 	return ansibleVaultDecrypt(cipher, password)
 }
 
+// IsLegacy returns whether the cipher uses the Ansible Vault format, which should be migrated to the v2 format.
+func IsLegacy(cipher string) bool {
+	return strings.HasPrefix(cipher, LegacyPrefix) || strings.HasPrefix(cipher, MasterPrefix+LegacyPrefix)
+}
+
+func decryptV2(cipher string, password string) ([]byte, error) {
+	parts := strings.Split(cipher[len(V2Prefix):], ":")
+	if len(parts) != 3 {
+		return nil, errors.New("invalid v2 cipher, expected parameters, salt and ciphertext")
+	}
+
+	var params KDFParams
+	_, err := fmt.Sscanf(parts[0], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads)
+	if err != nil {
+		return nil, fmt.Errorf("invalid v2 cipher parameters %s: %w", parts[0], err)
+	}
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+
+	key := deriveKey([]byte(password), salt, params)
+	defer Wipe(key)
+	return openGCM(sealed, key)
+}
+
+// Upper bounds of the KDF parameters. The parameters of a cipher are read from the snippets file, which can be
+// shared, so a corrupted or malicious cipher must not make deriving the key allocate unbounded memory.
+const (
+	maxKDFMemory = 4 * 1024 * 1024 // KiB, i.e. 4 GiB
+	maxKDFTime   = 100
+)
+
+// Validate checks that the parameters can be used with Argon2id and are within sane bounds.
+func (p KDFParams) Validate() error {
+	if p.Memory < 8*uint32(p.Threads) || p.Time < 1 || p.Threads < 1 {
+		return fmt.Errorf("invalid KDF parameters memory=%d, time=%d, threads=%d: all must be positive and memory at least 8*threads", p.Memory, p.Time, p.Threads)
+	}
+	if p.Memory > maxKDFMemory || p.Time > maxKDFTime {
+		return fmt.Errorf("invalid KDF parameters memory=%d, time=%d: memory must be at most %d and time at most %d", p.Memory, p.Time, maxKDFMemory, maxKDFTime)
+	}
+	return nil
+}
+
+func deriveKey(password []byte, salt []byte, params KDFParams) []byte {
+	return argon2.IDKey(password, salt, params.Time, params.Memory, params.Threads, 32)
+}
+
+// sealGCM encrypts and authenticates the plaintext with AES-256-GCM. The random nonce is prepended to the ciphertext.
+func sealGCM(plain []byte, key []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, nil), nil
+}
+
+func openGCM(sealed []byte, key []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		// Most likely a wrong password, since the ciphertext is authenticated.
+		return nil, errors.New("wrong password or corrupted secret")
+	}
+	return plain, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
 func ansibleVaultEncrypt(secret string, password string) (string, error) {
 	str, err := vault.Encrypt(secret, password)
 	if err != nil {
@@ -30,23 +179,23 @@ func ansibleVaultEncrypt(secret string, password string) (string, error) {
 		return "", err
 	}
 	b64 := base64.StdEncoding.EncodeToString(bytes)
-	return "AES256:" + b64, nil
+	return LegacyPrefix + b64, nil
 }
 
-func ansibleVaultDecrypt(cipher string, password string) (string, error) {
-	if !strings.HasPrefix(cipher, "AES256:") {
-		return "", fmt.Errorf("Cipher is missing AES256: prefix")
+func ansibleVaultDecrypt(cipher string, password string) ([]byte, error) {
+	if !strings.HasPrefix(cipher, LegacyPrefix) {
+		return nil, fmt.Errorf("Cipher is missing %s or %s prefix", V2Prefix, LegacyPrefix)
 	}
 
-	dec, err := base64.StdEncoding.DecodeString(cipher[7:])
+	dec, err := base64.StdEncoding.DecodeString(cipher[len(LegacyPrefix):])
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	hex := hex.EncodeToString(dec)
 	str, err := vault.Decrypt("$ANSIBLE_VAULT;1.1;AES256\n"+hex, password)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return str, nil
+	return []byte(str), nil
 }
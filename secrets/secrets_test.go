@@ -1,11 +1,15 @@
 package secrets
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// Cheap KDF parameters, so the tests run fast.
+var testKDFParams = KDFParams{Memory: 64, Time: 1, Threads: 1}
+
 func TestEncryptDecrypt(t *testing.T) {
 	cases := []struct {
 		plain    string
@@ -18,11 +22,77 @@ func TestEncryptDecrypt(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		cipher, err := Encrypt(c.plain, c.password)
+		cipher, err := EncryptWithParams([]byte(c.plain), []byte(c.password), testKDFParams)
 		assert.Nil(t, err)
 		assert.NotEqual(t, c.plain, cipher)
+		assert.True(t, strings.HasPrefix(cipher, "v2:argon2id:m=64,t=1,p=1:"))
 		dec, err := Decrypt(cipher, c.password)
 		assert.Nil(t, err)
-		assert.Equal(t, c.plain, dec)
+		assert.Equal(t, c.plain, string(dec))
+	}
+}
+
+func TestEncryptDefaultParams(t *testing.T) {
+	cipher, err := Encrypt([]byte("hello world"), []byte("password"))
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(cipher, "v2:argon2id:m=65536,t=3,p=4:"))
+
+	dec, err := Decrypt(cipher, "password")
+	assert.Nil(t, err)
+	assert.Equal(t, "hello world", string(dec))
+}
+
+func TestDecryptWrongPassword(t *testing.T) {
+	cipher, _ := EncryptWithParams([]byte("hello world"), []byte("password"), testKDFParams)
+
+	_, err := Decrypt(cipher, "wrong")
+
+	assert.Error(t, err)
+}
+
+func TestDecryptTamperedCipher(t *testing.T) {
+	cipher, _ := EncryptWithParams([]byte("hello world"), []byte("password"), testKDFParams)
+	parts := strings.Split(cipher, ":")
+	parts[len(parts)-1] = "A" + parts[len(parts)-1][1:]
+
+	_, err := Decrypt(strings.Join(parts, ":"), "password")
+
+	assert.Error(t, err)
+}
+
+func TestDecryptLegacy(t *testing.T) {
+	cipher, err := ansibleVaultEncrypt("hello world", "password")
+	assert.Nil(t, err)
+	assert.True(t, IsLegacy(cipher))
+
+	dec, err := Decrypt(cipher, "password")
+	assert.Nil(t, err)
+	assert.Equal(t, "hello world", string(dec))
+}
+
+func TestEncryptInvalidParams(t *testing.T) {
+	_, err := EncryptWithParams([]byte("hello world"), []byte("password"), KDFParams{Memory: 64, Time: 0, Threads: 1})
+
+	assert.Error(t, err)
+}
+
+func TestEncryptOversizedParams(t *testing.T) {
+	_, err := EncryptWithParams([]byte("hello world"), []byte("password"), KDFParams{Memory: 4*1024*1024 + 1, Time: 1, Threads: 1})
+	assert.Error(t, err)
+
+	_, err = EncryptWithParams([]byte("hello world"), []byte("password"), KDFParams{Memory: 64, Time: 101, Threads: 1})
+	assert.Error(t, err)
+}
+
+func TestDecryptOversizedParams(t *testing.T) {
+	cipher, _ := EncryptWithParams([]byte("hello world"), []byte("password"), testKDFParams)
+	parts := strings.Split(cipher, ":")
+
+	for _, params := range []string{"m=4294967295,t=1,p=1", "m=64,t=4294967295,p=1"} {
+		parts[2] = params
+		_, err := Decrypt(strings.Join(parts, ":"), "password")
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "must be at most")
 	}
 }
@@ -0,0 +1,152 @@
+package secrets
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Throttle slows down guessing passwords. After repeated failed attempts to unlock something, e.g. a secret
+// snippet or the master key, the next attempt has to wait, exponentially longer with each failure.
+type Throttle struct {
+	base time.Duration
+	max  time.Duration
+	// file is where the failed attempts are saved, so they are shared between processes and survive restarts.
+	// The failures are only kept in memory if it is empty.
+	file     string
+	lock     sync.Mutex
+	failures map[string]int
+	next     map[string]time.Time
+}
+
+// throttleEntry is the saved state of one key of a Throttle.
+type throttleEntry struct {
+	Failures int       `json:"failures"`
+	Next     time.Time `json:"next"`
+}
+
+// NewThrottle creates a Throttle. The first failed attempt is not delayed, so a typo can be corrected right away.
+// The second failure delays the next attempt by base, every further failure doubles the delay up to max.
+func NewThrottle(base time.Duration, max time.Duration) *Throttle {
+	return &Throttle{
+		base:     base,
+		max:      max,
+		failures: make(map[string]int),
+		next:     make(map[string]time.Time),
+	}
+}
+
+// NewFileThrottle creates a Throttle like NewThrottle that saves the failed attempts in the file. Every
+// Throttle with the same file, e.g. in the app and in CLI commands, shares them, and restarting does not
+// reset them. If the file cannot be read or written, the failures of this Throttle are still kept in memory.
+func NewFileThrottle(file string, base time.Duration, max time.Duration) *Throttle {
+	t := NewThrottle(base, max)
+	t.file = file
+	return t
+}
+
+// Wait returns how long to wait until the next attempt to unlock key is allowed. It is 0 if it is allowed now.
+func (t *Throttle) Wait(key string, now time.Time) time.Duration {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.load()
+	if wait := t.next[key].Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// Failed records a failed attempt to unlock key. It returns the delay until the next attempt.
+func (t *Throttle) Failed(key string, now time.Time) time.Duration {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.load()
+	t.failures[key]++
+	delay := t.delay(t.failures[key])
+	t.next[key] = now.Add(delay)
+	t.save()
+	return delay
+}
+
+// Succeeded resets the failed attempts of key.
+func (t *Throttle) Succeeded(key string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.load()
+	delete(t.failures, key)
+	delete(t.next, key)
+	t.save()
+}
+
+func (t *Throttle) delay(failures int) time.Duration {
+	if failures < 2 {
+		return 0
+	}
+	delay := t.base
+	for i := 2; i < failures && delay < t.max; i++ {
+		delay *= 2
+	}
+	if delay > t.max {
+		return t.max
+	}
+	return delay
+}
+
+// load replaces the failures in memory with the ones saved in the file, if it can be read.
+func (t *Throttle) load() {
+	if t.file == "" {
+		return
+	}
+	content, err := os.ReadFile(t.file)
+	if os.IsNotExist(err) {
+		t.failures = make(map[string]int)
+		t.next = make(map[string]time.Time)
+		return
+	}
+	var entries map[string]throttleEntry
+	if err != nil || json.Unmarshal(content, &entries) != nil {
+		return
+	}
+	t.failures = make(map[string]int, len(entries))
+	t.next = make(map[string]time.Time, len(entries))
+	for key, e := range entries {
+		t.failures[key] = e.Failures
+		t.next[key] = e.Next
+	}
+}
+
+// save writes the failures to the file. It is replaced atomically, so concurrent readers never see half of it.
+func (t *Throttle) save() {
+	if t.file == "" {
+		return
+	}
+	entries := make(map[string]throttleEntry, len(t.failures))
+	for key, failures := range t.failures {
+		entries[key] = throttleEntry{Failures: failures, Next: t.next[key]}
+	}
+	content, err := json.Marshal(entries)
+	if err != nil {
+		return
+	}
+	err = os.MkdirAll(filepath.Dir(t.file), 0700)
+	if err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(t.file), "."+filepath.Base(t.file)+".*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		os.Rename(tmp.Name(), t.file)
+	}
+}
@@ -0,0 +1,70 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottleBacksOffExponentially(t *testing.T) {
+	th := NewThrottle(time.Second, 10*time.Second)
+	now := time.Unix(1000, 0)
+
+	var delays []time.Duration
+	for i := 0; i < 6; i++ {
+		delays = append(delays, th.Failed("root", now))
+	}
+
+	assert.Equal(t, []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second}, delays)
+}
+
+func TestThrottleWait(t *testing.T) {
+	th := NewThrottle(time.Second, time.Minute)
+	now := time.Unix(1000, 0)
+	th.Failed("root", now)
+	assert.Equal(t, time.Duration(0), th.Wait("root", now))
+
+	th.Failed("root", now)
+	th.Failed("root", now)
+
+	assert.Equal(t, 2*time.Second, th.Wait("root", now))
+	assert.Equal(t, 500*time.Millisecond, th.Wait("root", now.Add(1500*time.Millisecond)))
+	assert.Equal(t, time.Duration(0), th.Wait("root", now.Add(2*time.Second)))
+	assert.Equal(t, time.Duration(0), th.Wait("other", now))
+}
+
+func TestThrottleSucceededResets(t *testing.T) {
+	th := NewThrottle(time.Second, time.Minute)
+	now := time.Unix(1000, 0)
+	th.Failed("root", now)
+	th.Failed("root", now)
+
+	th.Succeeded("root")
+
+	assert.Equal(t, time.Duration(0), th.Wait("root", now))
+	assert.Equal(t, time.Duration(0), th.Failed("root", now))
+}
+
+func TestFileThrottleIsShared(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "state", "unlock_failures")
+	now := time.Unix(1000, 0)
+	th := NewFileThrottle(file, time.Second, time.Minute)
+	th.Failed("root", now)
+	th.Failed("root", now)
+
+	other := NewFileThrottle(file, time.Second, time.Minute)
+	assert.Equal(t, time.Second, other.Wait("root", now))
+	assert.Equal(t, 2*time.Second, other.Failed("root", now))
+	assert.Equal(t, 2*time.Second, th.Wait("root", now))
+
+	other.Succeeded("root")
+	assert.Equal(t, time.Duration(0), th.Wait("root", now))
+	assert.Equal(t, time.Duration(0), th.Failed("root", now))
+
+	info, err := os.Stat(file)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
@@ -0,0 +1,624 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sandro-h/snippet/audit"
+	"github.com/sandro-h/snippet/secrets"
+	"github.com/sandro-h/snippet/util"
+	"golang.org/x/crypto/ssh/terminal"
+	"gopkg.in/yaml.v2"
+)
+
+// secretsCommand is a subcommand of "snippet secrets".
+type secretsCommand struct {
+	name        string
+	args        string
+	description string
+	run         func(args []string) error
+}
+
+var secretsCommands []*secretsCommand
+
+func init() {
+	secretsCommands = []*secretsCommand{
+		{name: "init-master", description: "Create the master key for master-password mode", run: runInitMaster},
+		{name: "encrypt", args: "[--master | --recipient R]", description: "Encrypt a secret with its own password, the master key or for age recipients", run: runEncrypt},
+		{name: "add", args: "[--file F] <label>", description: "Encrypt a secret like encrypt and add it as a new snippet", run: runAdd},
+		{name: "generate", args: "[--file F] <label>", description: "Generate a password, add it like add and print it", run: runGenerate},
+		{name: "show", args: "<label>", description: "Decrypt a secret snippet and print it to stdout", run: runShow},
+		{name: "rekey", args: "--file F", description: "Change the password of all password-encrypted secrets in a snippets file", run: runRekey},
+		{name: "migrate", description: "Re-encrypt all secrets in the Ansible Vault format with the current format", run: runMigrate},
+	}
+}
+
+func runSecrets(args []string) error {
+	if len(args) > 0 {
+		for _, c := range secretsCommands {
+			if c.name == args[0] {
+				return c.run(args[1:])
+			}
+		}
+	}
+
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s secrets <command>\n\nCommands:\n", filepath.Base(os.Args[0]))
+	for _, c := range secretsCommands {
+		fmt.Fprintf(out, "  %-30s %s\n", c.name+" "+c.args, c.description)
+	}
+	return errCancelled
+}
+
+func completeSecrets(args []string) []string {
+	if len(args) > 1 {
+		return nil
+	}
+	var names []string
+	for _, c := range secretsCommands {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// masterKeyFile contains the master key. It is next to config.yml, so it can be shared together with the snippets files.
+func masterKeyFile() string {
+	return filepath.Join(files.Dir, "master_key.yml")
+}
+
+func loadMasterKey() (*secrets.MasterKey, error) {
+	bytes, err := os.ReadFile(masterKeyFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("there is no master key yet, create one with \"snippet secrets init-master\"")
+	} else if err != nil {
+		return nil, err
+	}
+
+	var master secrets.MasterKey
+	err = yaml.Unmarshal(bytes, &master)
+	if err == nil && (master.Key == "" || master.Check == "") {
+		err = errors.New("missing key or check")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", masterKeyFile(), err)
+	}
+	return &master, nil
+}
+
+func saveMasterKey(master *secrets.MasterKey) error {
+	bytes, err := yaml.Marshal(master)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(masterKeyFile(), bytes, 0600)
+}
+
+// ageIdentityFile is the age identity file of the user, which decrypts secret_age snippets.
+func ageIdentityFile(c *config) string {
+	if c.ageIdentity != "" {
+		return c.ageIdentity
+	}
+	return filepath.Join(files.Dir, "age_identity.txt")
+}
+
+func readAgeIdentityFile(c *config) ([]byte, error) {
+	bytes, err := os.ReadFile(ageIdentityFile(c))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("there is no age identity at %s, create one with \"age-keygen -o %s\" or set age_identity in config.yml", ageIdentityFile(c), ageIdentityFile(c))
+	}
+	return bytes, err
+}
+
+// decryptAgeSecret decrypts an age secret with the identity file. The passphrase is only used if the identity file is encrypted.
+func decryptAgeSecret(secret string, identityFile []byte, passphrase string) ([]byte, error) {
+	identities, err := secrets.ParseAgeIdentities(identityFile, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return secrets.AgeDecrypt(secret, identities)
+}
+
+func runInitMaster(args []string) error {
+	if _, err := os.Stat(masterKeyFile()); err == nil {
+		return fmt.Errorf("%s already exists", masterKeyFile())
+	}
+	c, err := loadAppConfig()
+	if err != nil {
+		return err
+	}
+
+	pwd, err := readNewPassword("Master password")
+	if err != nil {
+		return err
+	}
+	master, err := secrets.NewMasterKey(pwd, c.kdf)
+	if err != nil {
+		return err
+	}
+
+	err = saveMasterKey(master)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Created", masterKeyFile())
+	return nil
+}
+
+// passwordEnv is the environment variable with the password for secrets, like --password-file. It is meant for
+// automation and tests.
+const passwordEnv = "SNIPPET_PASSWORD"
+
+// encryptFlags choose how "secrets encrypt" and "secrets add" encrypt a secret.
+type encryptFlags struct {
+	master          *bool
+	recipientKeys   stringsFlag
+	recipientsFiles stringsFlag
+	passwordFile    *string
+}
+
+func newEncryptFlags(fs *flag.FlagSet) *encryptFlags {
+	f := &encryptFlags{
+		master:       fs.Bool("master", false, "Encrypt with the master key instead of a password for this secret"),
+		passwordFile: fs.String("password-file", "", "Read the password or master password from the file instead of the terminal (env "+passwordEnv+")"),
+	}
+	fs.Var(&f.recipientKeys, "recipient", "Encrypt with age for the public key, for secret_age. Can be repeated")
+	fs.Var(&f.recipientsFiles, "recipients-file", "Encrypt with age for the public keys in the file, for secret_age. Can be repeated")
+	return f
+}
+
+func (f *encryptFlags) age() bool {
+	return len(f.recipientKeys) > 0 || len(f.recipientsFiles) > 0
+}
+
+// encrypt encrypts the secret and returns it with the snippet field it belongs in, secret or secret_age.
+func (f *encryptFlags) encrypt(c *config, secret []byte) (string, string, error) {
+	if *f.master && f.age() {
+		return "", "", errors.New("--master cannot be combined with --recipient or --recipients-file")
+	}
+
+	if f.age() {
+		var fileContents [][]byte
+		for _, file := range f.recipientsFiles {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return "", "", err
+			}
+			fileContents = append(fileContents, content)
+		}
+		recipients, err := secrets.ParseAgeRecipients(f.recipientKeys, fileContents)
+		if err != nil {
+			return "", "", err
+		}
+		enc, err := secrets.AgeEncrypt(secret, recipients)
+		return enc, "secret_age", err
+	}
+
+	pwd, ok, err := automationPassword(*f.passwordFile)
+	if err != nil {
+		return "", "", err
+	}
+
+	if *f.master {
+		var key []byte
+		if ok {
+			key, err = unlockMasterKey(pwd)
+		} else {
+			key, err = unlockMasterKeyFromTTY()
+		}
+		if err != nil {
+			return "", "", err
+		}
+		defer secrets.Wipe(key)
+		enc, err := secrets.EncryptWithMasterKey(secret, key)
+		return enc, "secret", err
+	}
+
+	if !ok {
+		pwd, err = readNewPassword("Password")
+		if err != nil {
+			return "", "", err
+		}
+	}
+	enc, err := secrets.EncryptWithParams(secret, []byte(pwd), c.kdf)
+	return enc, "secret", err
+}
+
+func runEncrypt(args []string) error {
+	fs := flag.NewFlagSet("secrets encrypt", flag.ExitOnError)
+	ef := newEncryptFlags(fs)
+	fs.Parse(args)
+
+	c, err := loadAppConfig()
+	if err != nil {
+		return err
+	}
+	secret, err := readSecret()
+	if err != nil {
+		return err
+	}
+	defer secrets.Wipe(secret)
+
+	enc, _, err := ef.encrypt(c, secret)
+	if err != nil {
+		return err
+	}
+	fmt.Println(enc)
+	return nil
+}
+
+// runAdd encrypts a secret and adds it as a new snippet to the snippets file.
+func runAdd(args []string) error {
+	fs := flag.NewFlagSet("secrets add", flag.ExitOnError)
+	ef := newEncryptFlags(fs)
+	file := fs.String("file", "", "Snippets file to add the secret to. Default: the first snippets file")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("add takes the label of the new snippet")
+	}
+	label := fs.Arg(0)
+
+	c, err := loadActiveConfig(profileOverride())
+	if err != nil {
+		return err
+	}
+	if *file == "" {
+		*file, err = defaultSnippetsFile(c)
+		if err != nil {
+			return err
+		}
+	}
+	secret, err := readSecret()
+	if err != nil {
+		return err
+	}
+	defer secrets.Wipe(secret)
+
+	enc, field, err := ef.encrypt(c, secret)
+	if err != nil {
+		return err
+	}
+	err = util.AddSecret(*file, label, field, enc)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Added %s to %s\n", label, *file)
+	return nil
+}
+
+// runShow decrypts a secret snippet and prints it to stdout.
+func runShow(args []string) error {
+	fs := flag.NewFlagSet("secrets show", flag.ExitOnError)
+	passwordFile := fs.String("password-file", "", "Read the password from the file instead of the terminal (env "+passwordEnv+")")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("show takes the label of the secret snippet")
+	}
+
+	snippets, c, err := loadSnippets()
+	if err != nil {
+		return err
+	}
+	var snippet *util.Snippet
+	for _, s := range snippets {
+		if s.Label == fs.Arg(0) {
+			snippet = s
+		}
+	}
+	if snippet == nil {
+		return fmt.Errorf("no snippet found for %q", fs.Arg(0))
+	}
+	if !snippet.IsSecret() {
+		return fmt.Errorf("snippet %s is not a secret", snippet.Label)
+	}
+
+	pwd, ok, err := automationPassword(*passwordFile)
+	if err != nil {
+		return err
+	}
+	p := &passwordPrompter{password: pwd, automated: ok}
+	content, err := renderSnippet(snippet, c, p)
+	defer secrets.Wipe(content)
+	if p.err != nil {
+		return p.err
+	}
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(content)
+	return err
+}
+
+// passwordPrompter answers password prompts with the password from --password-file or the environment,
+// or asks in the terminal if there is none.
+type passwordPrompter struct {
+	password  string
+	automated bool
+	err       error
+}
+
+func (p *passwordPrompter) Prompt(label string, masked bool) (string, bool) {
+	if p.automated && masked {
+		return p.password, true
+	}
+	val, ok, err := ttyPassword(label)
+	if err != nil {
+		p.err = err
+	}
+	return val, ok
+}
+
+// automationPassword returns the password from the password file, or from the environment if there is no
+// password file. The second return value is false if there is neither, so the password must be asked for.
+func automationPassword(passwordFile string) (string, bool, error) {
+	if passwordFile != "" {
+		bytes, err := os.ReadFile(passwordFile)
+		if err != nil {
+			return "", false, err
+		}
+		return strings.TrimRight(string(bytes), "\r\n"), true, nil
+	}
+	pwd, ok := os.LookupEnv(passwordEnv)
+	return pwd, ok, nil
+}
+
+// readSecret reads the secret from stdin if it is piped, or asks for it in the terminal.
+// The caller should wipe it after use with secrets.Wipe.
+func readSecret() ([]byte, error) {
+	if terminal.IsTerminal(int(os.Stdin.Fd())) {
+		secret, _, err := ttyPassword("Secret")
+		return []byte(secret), err
+	}
+	secret, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(bytes.TrimSuffix(secret, []byte("\n")), []byte("\r")), nil
+}
+
+// runMigrate re-encrypts the secrets in the Ansible Vault format in the snippets files of all profiles and
+// master_key.yml with the v2 format. The files are changed in place. Secrets that cannot be decrypted are kept as they are.
+func runMigrate(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("migrate takes no arguments")
+	}
+	c, err := loadActiveConfig(profileOverride())
+	if err != nil {
+		return err
+	}
+
+	// The master key is unlocked at most once and wiped when the migration is done.
+	var masterKey []byte
+	defer func() {
+		secrets.Wipe(masterKey)
+	}()
+	unlockMaster := func() ([]byte, error) {
+		if masterKey != nil {
+			return masterKey, nil
+		}
+		master, err := loadMasterKey()
+		if err != nil {
+			return nil, err
+		}
+		pwd, _, err := ttyPassword("Master password")
+		if err != nil {
+			return nil, err
+		}
+		key, err := master.Unlock(pwd)
+		if err != nil {
+			return nil, err
+		}
+
+		if secrets.IsLegacy(master.Key) {
+			master.Key, err = secrets.EncryptWithParams(key, []byte(pwd), c.kdf)
+			if err == nil {
+				err = saveMasterKey(master)
+			}
+			if err != nil {
+				secrets.Wipe(key)
+				recordAudit(audit.SecretMigrateFailed, "master key")
+				return nil, fmt.Errorf("could not migrate %s: %w", masterKeyFile(), err)
+			}
+			recordAudit(audit.SecretMigrated, "master key")
+			fmt.Println("Migrated", masterKeyFile())
+		}
+		masterKey = key
+		return key, nil
+	}
+
+	if master, err := loadMasterKey(); err == nil && secrets.IsLegacy(master.Key) {
+		if _, err := unlockMaster(); err != nil {
+			return err
+		}
+	}
+
+	// The secrets of a file are usually encrypted with the same password, so the passwords that were
+	// entered are tried first and the password is only asked for if none of them works.
+	var passwords []string
+	migrateSecret := func(label string, secret string) (string, error) {
+		if secrets.IsMasterSecret(secret) {
+			key, err := unlockMaster()
+			if err != nil {
+				return "", err
+			}
+			plain, err := secrets.DecryptWithMasterKey(secret, key)
+			if err != nil {
+				return "", err
+			}
+			defer secrets.Wipe(plain)
+			return secrets.EncryptWithMasterKey(plain, key)
+		}
+
+		plain, pwd, err := decryptWithKnownPasswords(secret, passwords)
+		if err != nil {
+			pwd, _, err = ttyPassword("Password for secret " + label)
+			if err != nil {
+				return "", err
+			}
+			plain, err = secrets.Decrypt(secret, pwd)
+			if err != nil {
+				return "", err
+			}
+			passwords = append(passwords, pwd)
+		}
+		defer secrets.Wipe(plain)
+		return secrets.EncryptWithParams(plain, []byte(pwd), c.kdf)
+	}
+
+	failed := 0
+	for _, f := range allSnippetsFiles(c) {
+		if _, err := os.Stat(f); os.IsNotExist(err) {
+			// Profiles can list files that were not created yet.
+			fmt.Fprintf(os.Stderr, "%s: skipped, the file does not exist\n", f)
+			continue
+		}
+		var migrated, notMigrated []string
+		n, snippetErrs, err := util.RewriteSecrets(f, func(label string, secret string) (string, error) {
+			if !secrets.IsLegacy(secret) {
+				return secret, nil
+			}
+			enc, err := migrateSecret(label, secret)
+			if err != nil {
+				notMigrated = append(notMigrated, label)
+			} else {
+				migrated = append(migrated, label)
+			}
+			return enc, err
+		})
+		if err != nil {
+			return err
+		}
+		recordRewrites(audit.SecretMigrated, migrated, audit.SecretMigrateFailed, notMigrated)
+
+		for _, e := range snippetErrs {
+			fmt.Fprintln(os.Stderr, e)
+		}
+		failed += len(snippetErrs)
+		fmt.Printf("%s: migrated %d secrets\n", f, n)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d secrets could not be migrated", failed)
+	}
+	return nil
+}
+
+// recordRewrites records the secrets that were re-encrypted in a snippets file, and the ones that could not be,
+// in the audit log. It must only be called once the file was written.
+func recordRewrites(succeeded audit.Event, succeededLabels []string, failed audit.Event, failedLabels []string) {
+	for _, l := range succeededLabels {
+		recordAudit(succeeded, l)
+	}
+	for _, l := range failedLabels {
+		recordAudit(failed, l)
+	}
+}
+
+// decryptWithKnownPasswords decrypts the secret with the first of the passwords that works. It returns the
+// decrypted secret and the password.
+func decryptWithKnownPasswords(secret string, passwords []string) ([]byte, string, error) {
+	for _, pwd := range passwords {
+		plain, err := secrets.Decrypt(secret, pwd)
+		if err == nil {
+			return plain, pwd, nil
+		}
+	}
+	return nil, "", errors.New("none of the passwords decrypts the secret")
+}
+
+// runRekey changes the password of the password-encrypted secrets in a snippets file. The file is changed in place.
+// Secrets that cannot be decrypted with the old password are kept as they are and reported, so files with
+// mixed passwords can be cleaned up. Master-key and age secrets are not affected.
+func runRekey(args []string) error {
+	fs := flag.NewFlagSet("secrets rekey", flag.ExitOnError)
+	file := fs.String("file", "", "Snippets file with the secrets to re-encrypt")
+	fs.Parse(args)
+	if *file == "" {
+		return errors.New("--file is required")
+	}
+
+	c, err := loadAppConfig()
+	if err != nil {
+		return err
+	}
+	oldPwd, _, err := ttyPassword("Old password")
+	if err != nil {
+		return err
+	}
+	newPwd, err := readNewPassword("New password")
+	if err != nil {
+		return err
+	}
+
+	var rekeyed, notRekeyed []string
+	n, snippetErrs, err := util.RewriteSecrets(*file, func(label string, secret string) (string, error) {
+		if secrets.IsMasterSecret(secret) {
+			return secret, nil
+		}
+		plain, err := secrets.Decrypt(secret, oldPwd)
+		if err != nil {
+			notRekeyed = append(notRekeyed, label)
+			return "", fmt.Errorf("could not decrypt with the old password: %w", err)
+		}
+		defer secrets.Wipe(plain)
+		enc, err := secrets.EncryptWithParams(plain, []byte(newPwd), c.kdf)
+		if err != nil {
+			notRekeyed = append(notRekeyed, label)
+		} else {
+			rekeyed = append(rekeyed, label)
+		}
+		return enc, err
+	})
+	if err != nil {
+		return err
+	}
+	recordRewrites(audit.SecretRekeyed, rekeyed, audit.SecretRekeyFailed, notRekeyed)
+
+	for _, e := range snippetErrs {
+		fmt.Fprintln(os.Stderr, e)
+	}
+	fmt.Printf("%s: rekeyed %d secrets\n", *file, n)
+	if len(snippetErrs) > 0 {
+		return fmt.Errorf("%d secrets could not be rekeyed", len(snippetErrs))
+	}
+	return nil
+}
+
+// unlockMasterKeyFromTTY asks for the master password and unlocks the master key with it.
+// The caller should wipe the key after use with secrets.Wipe.
+func unlockMasterKeyFromTTY() ([]byte, error) {
+	pwd, _, err := ttyPassword("Master password")
+	if err != nil {
+		return nil, err
+	}
+	return unlockMasterKey(pwd)
+}
+
+func unlockMasterKey(pwd string) ([]byte, error) {
+	master, err := loadMasterKey()
+	if err != nil {
+		return nil, err
+	}
+	return master.Unlock(pwd)
+}
+
+// readNewPassword asks for a new password twice, to avoid typos.
+func readNewPassword(label string) (string, error) {
+	pwd, _, err := ttyPassword(label)
+	if err != nil {
+		return "", err
+	}
+	repeated, _, err := ttyPassword("Repeat " + label)
+	if err != nil {
+		return "", err
+	}
+	if pwd != repeated {
+		return "", errors.New("the passwords do not match")
+	}
+	return pwd, nil
+}
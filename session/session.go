@@ -0,0 +1,232 @@
+package session
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Event is a change of the user session after which unlocked secrets should be locked.
+type Event int
+
+const (
+	// EventLocked is sent when the screen is locked.
+	EventLocked Event = iota
+	// EventSuspended is sent when the machine is about to suspend, or has resumed from a suspend that was not announced.
+	EventSuspended
+)
+
+func (e Event) String() string {
+	switch e {
+	case EventLocked:
+		return "screen locked"
+	case EventSuspended:
+		return "suspended"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	login1              = "org.freedesktop.login1"
+	login1Manager       = "org.freedesktop.login1.Manager"
+	login1Session       = "org.freedesktop.login1.Session"
+	propertiesInterface = "org.freedesktop.DBus.Properties"
+)
+
+// screenSaverInterfaces announce screen locks on the session bus. GNOME uses its own interface.
+var screenSaverInterfaces = []string{"org.freedesktop.ScreenSaver", "org.gnome.ScreenSaver"}
+
+// Logind watches logind on the system bus for suspends and locks of the user's session, and the screen saver on the
+// session bus for screen locks.
+type Logind struct {
+	systemBus  *dbus.Conn
+	sessionBus *dbus.Conn
+	signals    chan *dbus.Signal
+}
+
+// WatchLogind starts watching logind and calls onEvent for every session event, on a separate goroutine.
+// It returns an error if the system bus is not available. The screen saver is only watched if there is a session bus.
+func WatchLogind(onEvent func(Event)) (*Logind, error) {
+	systemBus, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Logind{systemBus: systemBus, signals: make(chan *dbus.Signal, 10)}
+	sessionPath := findSessionPath(systemBus)
+	err = systemBus.AddMatchSignal(dbus.WithMatchInterface(login1Manager), dbus.WithMatchMember("PrepareForSleep"))
+	if err == nil {
+		err = systemBus.AddMatchSignal(dbus.WithMatchInterface(login1Session), dbus.WithMatchMember("Lock"))
+	}
+	if err == nil {
+		err = systemBus.AddMatchSignal(dbus.WithMatchInterface(propertiesInterface), dbus.WithMatchMember("PropertiesChanged"),
+			dbus.WithMatchArg(0, login1Session))
+	}
+	if err != nil {
+		systemBus.Close()
+		return nil, err
+	}
+	systemBus.Signal(l.signals)
+
+	if sessionBus, err := dbus.ConnectSessionBus(); err == nil {
+		l.sessionBus = sessionBus
+		for _, iface := range screenSaverInterfaces {
+			sessionBus.AddMatchSignal(dbus.WithMatchInterface(iface), dbus.WithMatchMember("ActiveChanged"))
+		}
+		sessionBus.Signal(l.signals)
+	}
+
+	go func() {
+		for s := range l.signals {
+			if e, ok := eventFor(s, sessionPath); ok {
+				onEvent(e)
+			}
+		}
+	}()
+	return l, nil
+}
+
+// Close stops watching.
+func (l *Logind) Close() error {
+	if l.sessionBus != nil {
+		l.sessionBus.RemoveSignal(l.signals)
+		l.sessionBus.Close()
+	}
+	l.systemBus.RemoveSignal(l.signals)
+	err := l.systemBus.Close()
+	close(l.signals)
+	return err
+}
+
+// findSessionPath returns the object path of the session this process runs in, or an empty path if it is unknown.
+func findSessionPath(systemBus *dbus.Conn) dbus.ObjectPath {
+	manager := systemBus.Object(login1, "/org/freedesktop/login1")
+	var path dbus.ObjectPath
+	if manager.Call(login1Manager+".GetSessionByPID", 0, uint32(os.Getpid())).Store(&path) == nil {
+		return path
+	}
+	if manager.Call(login1Manager+".GetSession", 0, "auto").Store(&path) == nil {
+		return path
+	}
+	return ""
+}
+
+// eventFor returns the session event of the D-Bus signal, if it is one. Session locks are only taken from the
+// session with the given path, or from any session if the path is empty.
+func eventFor(s *dbus.Signal, sessionPath dbus.ObjectPath) (Event, bool) {
+	ownSession := sessionPath == "" || s.Path == sessionPath
+	switch s.Name {
+	case login1Manager + ".PrepareForSleep":
+		return EventSuspended, firstArgTrue(s)
+	case login1Session + ".Lock":
+		return EventLocked, ownSession
+	case propertiesInterface + ".PropertiesChanged":
+		if !ownSession || len(s.Body) < 2 || s.Body[0] != login1Session {
+			return 0, false
+		}
+		changed, ok := s.Body[1].(map[string]dbus.Variant)
+		if !ok {
+			return 0, false
+		}
+		locked, ok := changed["LockedHint"].Value().(bool)
+		return EventLocked, ok && locked
+	}
+	for _, iface := range screenSaverInterfaces {
+		if s.Name == iface+".ActiveChanged" {
+			return EventLocked, firstArgTrue(s)
+		}
+	}
+	return 0, false
+}
+
+func firstArgTrue(s *dbus.Signal) bool {
+	if len(s.Body) == 0 {
+		return false
+	}
+	b, ok := s.Body[0].(bool)
+	return ok && b
+}
+
+// suspendGap is how far the wall clock must jump ahead of the monotonic clock between two polls to count as a suspend.
+const suspendGap = 10 * time.Second
+
+// Poller is a stand-in for Logind where D-Bus is not available, and in tests. It polls whether the session
+// is locked, and detects suspends by the wall clock jumping ahead of the monotonic clock, which does not
+// advance while suspended.
+type Poller struct {
+	// locked returns whether the session is locked. If nil, only suspends are detected.
+	locked  func() (bool, error)
+	onEvent func(Event)
+	wall    func() time.Time
+	mono    func() time.Duration
+
+	lock      sync.Mutex
+	wasLocked bool
+	lastWall  time.Time
+	lastMono  time.Duration
+	done      chan struct{}
+}
+
+// Poll starts polling every interval and calls onEvent for every session event, on a separate goroutine.
+func Poll(interval time.Duration, locked func() (bool, error), onEvent func(Event)) *Poller {
+	start := time.Now()
+	p := newPoller(locked, onEvent, func() time.Time { return time.Now().Round(0) }, func() time.Duration { return time.Since(start) })
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.poll()
+			case <-p.done:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+func newPoller(locked func() (bool, error), onEvent func(Event), wall func() time.Time, mono func() time.Duration) *Poller {
+	return &Poller{
+		locked:   locked,
+		onEvent:  onEvent,
+		wall:     wall,
+		mono:     mono,
+		lastWall: wall(),
+		lastMono: mono(),
+		done:     make(chan struct{}),
+	}
+}
+
+func (p *Poller) poll() {
+	p.lock.Lock()
+	var events []Event
+	wall, mono := p.wall(), p.mono()
+	if wall.Sub(p.lastWall)-(mono-p.lastMono) > suspendGap {
+		events = append(events, EventSuspended)
+	}
+	p.lastWall, p.lastMono = wall, mono
+
+	if p.locked != nil {
+		if locked, err := p.locked(); err == nil {
+			if locked && !p.wasLocked {
+				events = append(events, EventLocked)
+			}
+			p.wasLocked = locked
+		}
+	}
+	p.lock.Unlock()
+
+	for _, e := range events {
+		p.onEvent(e)
+	}
+}
+
+// Close stops polling.
+func (p *Poller) Close() error {
+	close(p.done)
+	return nil
+}
@@ -0,0 +1,139 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventFor(t *testing.T) {
+	own := dbus.ObjectPath("/org/freedesktop/login1/session/_32")
+	other := dbus.ObjectPath("/org/freedesktop/login1/session/_33")
+	lockedHint := func(path dbus.ObjectPath, locked bool) *dbus.Signal {
+		return &dbus.Signal{Path: path, Name: "org.freedesktop.DBus.Properties.PropertiesChanged", Body: []interface{}{
+			"org.freedesktop.login1.Session", map[string]dbus.Variant{"LockedHint": dbus.MakeVariant(locked)}, []string{},
+		}}
+	}
+
+	cases := []struct {
+		name   string
+		signal *dbus.Signal
+		event  Event
+		ok     bool
+	}{
+		{"sleep", &dbus.Signal{Name: "org.freedesktop.login1.Manager.PrepareForSleep", Body: []interface{}{true}}, EventSuspended, true},
+		{"resume", &dbus.Signal{Name: "org.freedesktop.login1.Manager.PrepareForSleep", Body: []interface{}{false}}, EventSuspended, false},
+		{"lock", &dbus.Signal{Path: own, Name: "org.freedesktop.login1.Session.Lock"}, EventLocked, true},
+		{"lock other session", &dbus.Signal{Path: other, Name: "org.freedesktop.login1.Session.Lock"}, EventLocked, false},
+		{"locked hint", lockedHint(own, true), EventLocked, true},
+		{"unlocked hint", lockedHint(own, false), EventLocked, false},
+		{"locked hint other session", lockedHint(other, true), EventLocked, false},
+		{"screen saver", &dbus.Signal{Name: "org.gnome.ScreenSaver.ActiveChanged", Body: []interface{}{true}}, EventLocked, true},
+		{"screen saver off", &dbus.Signal{Name: "org.freedesktop.ScreenSaver.ActiveChanged", Body: []interface{}{false}}, EventLocked, false},
+		{"unrelated", &dbus.Signal{Name: "org.freedesktop.login1.Manager.SessionNew", Body: []interface{}{"32", own}}, 0, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e, ok := eventFor(c.signal, own)
+			assert.Equal(t, c.ok, ok)
+			if c.ok {
+				assert.Equal(t, c.event, e)
+			}
+		})
+	}
+}
+
+func TestEventForUnknownSession(t *testing.T) {
+	e, ok := eventFor(&dbus.Signal{Path: "/org/freedesktop/login1/session/_33", Name: "org.freedesktop.login1.Session.Lock"}, "")
+
+	assert.True(t, ok)
+	assert.Equal(t, EventLocked, e)
+}
+
+type fakeClock struct {
+	wall time.Time
+	mono time.Duration
+}
+
+func (c *fakeClock) advance(wall time.Duration, mono time.Duration) {
+	c.wall = c.wall.Add(wall)
+	c.mono += mono
+}
+
+func newFakePoller(locked func() (bool, error)) (*Poller, *fakeClock, *[]Event) {
+	clock := &fakeClock{wall: time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)}
+	var events []Event
+	p := newPoller(locked, func(e Event) { events = append(events, e) },
+		func() time.Time { return clock.wall }, func() time.Duration { return clock.mono })
+	return p, clock, &events
+}
+
+func TestPollerDetectsSuspend(t *testing.T) {
+	p, clock, events := newFakePoller(nil)
+
+	clock.advance(5*time.Second, 5*time.Second)
+	p.poll()
+	assert.Empty(t, *events)
+
+	// The monotonic clock stands still while suspended.
+	clock.advance(2*time.Hour, 5*time.Second)
+	p.poll()
+	assert.Equal(t, []Event{EventSuspended}, *events)
+
+	clock.advance(5*time.Second, 5*time.Second)
+	p.poll()
+	assert.Equal(t, []Event{EventSuspended}, *events)
+}
+
+func TestPollerDetectsLock(t *testing.T) {
+	locked := false
+	var err error
+	p, clock, events := newFakePoller(func() (bool, error) { return locked, err })
+
+	clock.advance(time.Second, time.Second)
+	p.poll()
+	locked = true
+	p.poll()
+	p.poll()
+	assert.Equal(t, []Event{EventLocked}, *events)
+
+	err = errors.New("no session")
+	locked = false
+	p.poll()
+	err = nil
+	locked = true
+	p.poll()
+	assert.Equal(t, []Event{EventLocked}, *events)
+
+	locked = false
+	p.poll()
+	locked = true
+	p.poll()
+	assert.Equal(t, []Event{EventLocked, EventLocked}, *events)
+}
+
+func TestPoll(t *testing.T) {
+	locked := make(chan bool, 1)
+	locked <- true
+	events := make(chan Event, 1)
+
+	p := Poll(time.Millisecond, func() (bool, error) {
+		select {
+		case l := <-locked:
+			return l, nil
+		default:
+			return true, nil
+		}
+	}, func(e Event) { events <- e })
+	defer p.Close()
+
+	select {
+	case e := <-events:
+		assert.Equal(t, EventLocked, e)
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+	}
+}
@@ -0,0 +1,8 @@
+_{{.Func}}_complete() {
+	local IFS=$'\n' candidate
+	COMPREPLY=()
+	for candidate in $({{.Bin}} __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null); do
+		COMPREPLY+=("$(printf '%q' "$candidate")")
+	done
+}
+complete -F _{{.Func}}_complete {{.Name}}
@@ -0,0 +1 @@
+complete -c {{.Name}} -f -a '({{.Bin}} __complete (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null)'
@@ -0,0 +1,9 @@
+
+# Ctrl+X S opens the snippet picker and inserts the chosen snippet at the cursor.
+_{{.Func}}_widget() {
+	local snippet
+	snippet=$({{.Bin}} tui </dev/tty) || return
+	READLINE_LINE="${READLINE_LINE:0:$READLINE_POINT}${snippet}${READLINE_LINE:$READLINE_POINT}"
+	READLINE_POINT=$((READLINE_POINT + ${#snippet}))
+}
+bind -x '"\C-xs": _{{.Func}}_widget'
@@ -0,0 +1,8 @@
+
+# Ctrl+X S opens the snippet picker and inserts the chosen snippet at the cursor.
+function _{{.Func}}_widget
+	set -l snippet ({{.Bin}} tui </dev/tty | string collect)
+	and commandline -i -- $snippet
+	commandline -f repaint
+end
+bind \cxs _{{.Func}}_widget
@@ -0,0 +1,72 @@
+package shell
+
+import (
+	"embed"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+//go:embed scripts
+var scripts embed.FS
+
+// Shells lists the supported shells.
+var Shells = []string{"bash", "zsh", "fish"}
+
+var nonIdentChars = regexp.MustCompile("[^a-zA-Z0-9_]")
+
+type scriptData struct {
+	// Name of the command to complete.
+	Name string
+	// Name usable in shell function names.
+	Func string
+	// Quoted path to the executable.
+	Bin string
+}
+
+// Completion returns the script for the shell that completes the subcommands, flags and snippet labels
+// of the snippet CLI. name is the command name to complete and bin the path to the executable.
+func Completion(shell string, name string, bin string) (string, error) {
+	return render(shell, name, bin, "completion")
+}
+
+// Init returns the script for the shell that sets up completion, as well as a keybinding (Ctrl+X S)
+// that opens the terminal picker and inserts the chosen snippet into the command line.
+func Init(shell string, name string, bin string) (string, error) {
+	return render(shell, name, bin, "completion", "init")
+}
+
+func render(shell string, name string, bin string, scriptNames ...string) (string, error) {
+	if !isSupported(shell) {
+		return "", fmt.Errorf("unsupported shell %s, must be one of: %s", shell, strings.Join(Shells, ", "))
+	}
+
+	data := scriptData{
+		Name: name,
+		Func: nonIdentChars.ReplaceAllString(name, "_"),
+		Bin:  "'" + strings.ReplaceAll(bin, "'", `'\''`) + "'",
+	}
+
+	var sb strings.Builder
+	for _, s := range scriptNames {
+		tmpl, err := template.ParseFS(scripts, "scripts/"+s+"."+shell)
+		if err != nil {
+			return "", err
+		}
+		err = tmpl.Execute(&sb, data)
+		if err != nil {
+			return "", err
+		}
+	}
+	return sb.String(), nil
+}
+
+func isSupported(shell string) bool {
+	for _, s := range Shells {
+		if s == shell {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompletion(t *testing.T) {
+	for _, s := range Shells {
+		script, err := Completion(s, "snippet", "/usr/local/bin/snippet")
+		assert.Nil(t, err)
+		assert.Contains(t, script, "'/usr/local/bin/snippet' __complete")
+		assert.NotContains(t, script, "_widget")
+	}
+}
+
+func TestInit(t *testing.T) {
+	for _, s := range Shells {
+		script, err := Init(s, "snippet", "/usr/local/bin/snippet")
+		assert.Nil(t, err)
+		assert.Contains(t, script, "'/usr/local/bin/snippet' __complete")
+		assert.Contains(t, script, "'/usr/local/bin/snippet' tui </dev/tty")
+	}
+}
+
+func TestQuotesBinAndSanitizesName(t *testing.T) {
+	script, err := Init("zsh", "my-snippet", "/home/o'neil/my-snippet")
+	assert.Nil(t, err)
+	assert.Contains(t, script, `'/home/o'\''neil/my-snippet' tui`)
+	assert.Contains(t, script, "zle -N _my_snippet_widget")
+	assert.Contains(t, script, "compdef _my_snippet_complete my-snippet")
+}
+
+func TestUnsupportedShell(t *testing.T) {
+	_, err := Completion("powershell", "snippet", "snippet")
+	assert.EqualError(t, err, "unsupported shell powershell, must be one of: bash, zsh, fish")
+}
@@ -0,0 +1,257 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sandro-h/snippet/secrets"
+	"github.com/sandro-h/snippet/util"
+)
+
+// EventKind describes what changed in the store.
+type EventKind int
+
+const (
+	// EventReplaced is sent when the snippet list was replaced, e.g. after reloading the snippets file.
+	EventReplaced EventKind = iota
+	// EventSecretUnlocked is sent when a secret snippet was decrypted.
+	EventSecretUnlocked
+	// EventSecretsEvicted is sent when decrypted secrets were evicted again.
+	EventSecretsEvicted
+	// EventMasterKeyUnlocked is sent when the master key was unlocked with the master password.
+	EventMasterKeyUnlocked
+	// EventMasterKeyLocked is sent when the unlocked master key was evicted again.
+	EventMasterKeyLocked
+)
+
+// Event describes a change in the store.
+type Event struct {
+	Kind EventKind
+	// Snippets is a snapshot of the snippet list after the change.
+	Snippets []*util.Snippet
+	// Labels of the snippets affected by the change, if the change only affected some snippets.
+	Labels []string
+}
+
+// Store owns the list of snippets and makes it safe to use from multiple goroutines, e.g. the file watcher,
+// the secret eviction and the UI.
+// The snippets returned by the store must be treated as read-only. Their runtime state (SecretDecrypted
+// and SecretLastUsed) must only be accessed through the store.
+type Store struct {
+	lock      sync.RWMutex
+	writeLock sync.Mutex // Serializes changes, so subscribers see the events in the order of the changes.
+	snippets  []*util.Snippet
+	// masterKey is the unlocked master key in a locked buffer, or nil if it is locked.
+	masterKey         *secrets.Buffer
+	masterKeyLastUsed time.Time
+	subscribersLock   sync.Mutex
+	subscribers       map[int]func(Event)
+	nextSubscriberID  int
+}
+
+// New creates a new Store with an initial list of snippets.
+func New(snippets []*util.Snippet) *Store {
+	return &Store{
+		snippets:    snippets,
+		subscribers: make(map[int]func(Event)),
+	}
+}
+
+// Snapshot returns the current list of snippets. The list is not affected by later changes to the store.
+func (s *Store) Snapshot() []*util.Snippet {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.snapshot()
+}
+
+// Find returns the snippet with the given label, or nil if there is none.
+func (s *Store) Find(label string) *util.Snippet {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.find(label)
+}
+
+// Replace replaces the list of snippets and transfers the runtime state of the old snippets
+// to the new snippets with the same label.
+func (s *Store) Replace(snippets []*util.Snippet) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	s.lock.Lock()
+	old := make(map[string]*util.Snippet)
+	for _, o := range s.snippets {
+		old[o.Label] = o
+	}
+	for _, n := range snippets {
+		if o, ok := old[n.Label]; ok && o.Secret == n.Secret && o.SecretAge == n.SecretAge {
+			n.SecretDecrypted = o.SecretDecrypted
+			n.SecretLastUsed = o.SecretLastUsed
+			delete(old, n.Label)
+		}
+	}
+	// Secrets that were removed or changed are wiped.
+	for _, o := range old {
+		o.SecretDecrypted.Destroy()
+		o.SecretDecrypted = nil
+	}
+	s.snippets = snippets
+	snapshot := s.snapshot()
+	s.lock.Unlock()
+
+	s.publish(Event{Kind: EventReplaced, Snippets: snapshot})
+}
+
+// UnlockedSecret returns a copy of the decrypted secret of the snippet with the given label, if it is unlocked.
+// The caller should wipe the copy after use with secrets.Wipe. Using the secret counts as activity for the secret's TTL.
+func (s *Store) UnlockedSecret(label string) ([]byte, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	snippet := s.find(label)
+	if snippet == nil || snippet.SecretDecrypted == nil {
+		return nil, false
+	}
+	snippet.SecretLastUsed = time.Now()
+	return append([]byte(nil), snippet.SecretDecrypted.Bytes()...), true
+}
+
+// UnlockSecret remembers a copy of the decrypted secret of the snippet with the given label in a locked buffer,
+// until it is evicted.
+func (s *Store) UnlockSecret(label string, decrypted []byte) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	s.lock.Lock()
+	snippet := s.find(label)
+	if snippet == nil {
+		s.lock.Unlock()
+		return
+	}
+	snippet.SecretDecrypted.Destroy()
+	snippet.SecretDecrypted = secrets.NewBuffer(decrypted)
+	snippet.SecretLastUsed = time.Now()
+	snapshot := s.snapshot()
+	s.lock.Unlock()
+
+	s.publish(Event{Kind: EventSecretUnlocked, Snippets: snapshot, Labels: []string{label}})
+}
+
+// UnlockedMasterKey returns a copy of the unlocked master key, if it is unlocked.
+// The caller should wipe the copy after use with secrets.Wipe. Using the master key counts as activity for its TTL.
+func (s *Store) UnlockedMasterKey() ([]byte, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.masterKey == nil {
+		return nil, false
+	}
+	s.masterKeyLastUsed = time.Now()
+	return append([]byte(nil), s.masterKey.Bytes()...), true
+}
+
+// UnlockMasterKey remembers a copy of the unlocked master key in a locked buffer, until it is evicted.
+func (s *Store) UnlockMasterKey(key []byte) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	s.lock.Lock()
+	s.masterKey.Destroy()
+	s.masterKey = secrets.NewBuffer(key)
+	s.masterKeyLastUsed = time.Now()
+	snapshot := s.snapshot()
+	s.lock.Unlock()
+
+	s.publish(Event{Kind: EventMasterKeyUnlocked, Snippets: snapshot})
+}
+
+// EvictSecrets wipes all decrypted secrets and forgets the master key if they were not used for longer than the ttl.
+// Snippets with their own SecretTTL use it instead of the ttl, unless the ttl is negative, which evicts everything.
+// It returns the labels of the evicted snippets.
+func (s *Store) EvictSecrets(ttl time.Duration, now time.Time) []string {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	s.lock.Lock()
+	var evicted []string
+	for _, snippet := range s.snippets {
+		snippetTTL := ttl
+		if snippet.SecretTTL > 0 && ttl >= 0 {
+			snippetTTL = snippet.SecretTTL
+		}
+		if snippet.SecretDecrypted != nil && now.Sub(snippet.SecretLastUsed) > snippetTTL {
+			snippet.SecretDecrypted.Destroy()
+			snippet.SecretDecrypted = nil
+			evicted = append(evicted, snippet.Label)
+		}
+	}
+	masterKeyEvicted := s.masterKey != nil && now.Sub(s.masterKeyLastUsed) > ttl
+	if masterKeyEvicted {
+		s.masterKey.Destroy()
+		s.masterKey = nil
+	}
+	snapshot := s.snapshot()
+	s.lock.Unlock()
+
+	if len(evicted) > 0 {
+		s.publish(Event{Kind: EventSecretsEvicted, Snippets: snapshot, Labels: evicted})
+	}
+	if masterKeyEvicted {
+		s.publish(Event{Kind: EventMasterKeyLocked, Snippets: snapshot})
+	}
+	return evicted
+}
+
+// EvictAllSecrets forgets all decrypted secrets and the master key, regardless of when they were used.
+// It returns the labels of the evicted snippets.
+func (s *Store) EvictAllSecrets() []string {
+	return s.EvictSecrets(-1, time.Now())
+}
+
+// Subscribe registers a function that is called for every change of the store. It is called synchronously
+// on the goroutine that made the change, after the change is done. It may read from the store,
+// but must not change it.
+// The returned function cancels the subscription.
+func (s *Store) Subscribe(onEvent func(Event)) func() {
+	s.subscribersLock.Lock()
+	defer s.subscribersLock.Unlock()
+
+	id := s.nextSubscriberID
+	s.nextSubscriberID++
+	s.subscribers[id] = onEvent
+
+	return func() {
+		s.subscribersLock.Lock()
+		defer s.subscribersLock.Unlock()
+		delete(s.subscribers, id)
+	}
+}
+
+func (s *Store) publish(event Event) {
+	s.subscribersLock.Lock()
+	subscribers := make([]func(Event), 0, len(s.subscribers))
+	for id := 0; id < s.nextSubscriberID; id++ {
+		if sub, ok := s.subscribers[id]; ok {
+			subscribers = append(subscribers, sub)
+		}
+	}
+	s.subscribersLock.Unlock()
+
+	for _, sub := range subscribers {
+		sub(event)
+	}
+}
+
+func (s *Store) snapshot() []*util.Snippet {
+	snapshot := make([]*util.Snippet, len(s.snippets))
+	copy(snapshot, s.snippets)
+	return snapshot
+}
+
+func (s *Store) find(label string) *util.Snippet {
+	for _, snippet := range s.snippets {
+		if snippet.Label == label {
+			return snippet
+		}
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sandro-h/snippet/secrets"
+	"github.com/sandro-h/snippet/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func testSnippets() []*util.Snippet {
+	return []*util.Snippet{
+		{Label: "foo", Content: "bar"},
+		{Label: "pwd", Content: "******", Secret: "AES256:abc"},
+	}
+}
+
+func TestSnapshotIsIndependent(t *testing.T) {
+	s := New(testSnippets())
+
+	snapshot := s.Snapshot()
+	s.Replace([]*util.Snippet{{Label: "new"}})
+
+	assert.Len(t, snapshot, 2)
+	assert.Equal(t, "foo", snapshot[0].Label)
+	assert.Equal(t, "new", s.Snapshot()[0].Label)
+}
+
+func TestReplaceTransfersUnlockedSecret(t *testing.T) {
+	s := New(testSnippets())
+	s.UnlockSecret("pwd", []byte("hunter2"))
+
+	s.Replace(testSnippets())
+
+	secret, ok := s.UnlockedSecret("pwd")
+	assert.True(t, ok)
+	assert.Equal(t, "hunter2", string(secret))
+}
+
+func TestUnlockedSecretReturnsCopy(t *testing.T) {
+	s := New(testSnippets())
+	decrypted := []byte("hunter2")
+	s.UnlockSecret("pwd", decrypted)
+	decrypted[0] = 'x'
+
+	secret, _ := s.UnlockedSecret("pwd")
+	secret[1] = 'x'
+
+	again, _ := s.UnlockedSecret("pwd")
+	assert.Equal(t, "hunter2", string(again))
+}
+
+func TestReplaceDoesNotTransferChangedSecret(t *testing.T) {
+	s := New(testSnippets())
+	s.UnlockSecret("pwd", []byte("hunter2"))
+
+	old := s.Find("pwd")
+	changed := testSnippets()
+	changed[1].Secret = "AES256:def"
+	s.Replace(changed)
+
+	_, ok := s.UnlockedSecret("pwd")
+	assert.False(t, ok)
+	assert.Nil(t, old.SecretDecrypted)
+}
+
+func TestReplaceWipesRemovedSecret(t *testing.T) {
+	s := New(testSnippets())
+	s.UnlockSecret("pwd", []byte("hunter2"))
+	old := s.Find("pwd")
+
+	s.Replace([]*util.Snippet{{Label: "foo", Content: "bar"}})
+
+	assert.Nil(t, old.SecretDecrypted)
+}
+
+func TestEvictSecrets(t *testing.T) {
+	s := New(testSnippets())
+	s.UnlockSecret("pwd", []byte("hunter2"))
+
+	assert.Empty(t, s.EvictSecrets(time.Minute, time.Now()))
+	assert.Equal(t, []string{"pwd"}, s.EvictSecrets(time.Minute, time.Now().Add(2*time.Minute)))
+
+	_, ok := s.UnlockedSecret("pwd")
+	assert.False(t, ok)
+	assert.Nil(t, s.Find("pwd").SecretDecrypted)
+}
+
+func TestEvictSecretsWithSnippetTTL(t *testing.T) {
+	snippets := testSnippets()
+	snippets = append(snippets, &util.Snippet{Label: "root", Content: "******", Secret: "v2:abc", SecretTTL: 10 * time.Second})
+	s := New(snippets)
+	s.UnlockSecret("pwd", []byte("hunter2"))
+	s.UnlockSecret("root", []byte("toor"))
+
+	assert.Equal(t, []string{"root"}, s.EvictSecrets(time.Minute, time.Now().Add(30*time.Second)))
+	assert.Equal(t, []string{"pwd"}, s.EvictSecrets(time.Minute, time.Now().Add(2*time.Minute)))
+}
+
+func TestEvictAllSecretsIgnoresSnippetTTL(t *testing.T) {
+	s := New([]*util.Snippet{{Label: "root", Content: "******", Secret: "v2:abc", SecretTTL: time.Hour}})
+	s.UnlockSecret("root", []byte("toor"))
+
+	assert.Equal(t, []string{"root"}, s.EvictAllSecrets())
+}
+
+func TestEvictAllSecrets(t *testing.T) {
+	s := New(testSnippets())
+	s.UnlockSecret("pwd", []byte("hunter2"))
+
+	assert.Equal(t, []string{"pwd"}, s.EvictAllSecrets())
+
+	_, ok := s.UnlockedSecret("pwd")
+	assert.False(t, ok)
+}
+
+func TestMasterKey(t *testing.T) {
+	s := New(testSnippets())
+	_, ok := s.UnlockedMasterKey()
+	assert.False(t, ok)
+
+	unlocked := []byte("key")
+	s.UnlockMasterKey(unlocked)
+	secrets.Wipe(unlocked)
+	key, ok := s.UnlockedMasterKey()
+	assert.True(t, ok)
+	assert.Equal(t, []byte("key"), key)
+	secrets.Wipe(key)
+	key, _ = s.UnlockedMasterKey()
+	assert.Equal(t, []byte("key"), key)
+
+	s.EvictSecrets(time.Minute, time.Now())
+	_, ok = s.UnlockedMasterKey()
+	assert.True(t, ok)
+
+	var events []Event
+	s.Subscribe(func(e Event) {
+		events = append(events, e)
+	})
+	s.EvictSecrets(time.Minute, time.Now().Add(2*time.Minute))
+	_, ok = s.UnlockedMasterKey()
+	assert.False(t, ok)
+	assert.Len(t, events, 1)
+	assert.Equal(t, EventMasterKeyLocked, events[0].Kind)
+}
+
+func TestEvictAllSecretsLocksMasterKey(t *testing.T) {
+	s := New(testSnippets())
+	s.UnlockMasterKey([]byte("key"))
+
+	s.EvictAllSecrets()
+
+	_, ok := s.UnlockedMasterKey()
+	assert.False(t, ok)
+}
+
+func TestSubscribe(t *testing.T) {
+	s := New(testSnippets())
+
+	var events []Event
+	unsubscribe := s.Subscribe(func(e Event) {
+		events = append(events, e)
+	})
+
+	s.UnlockSecret("pwd", []byte("hunter2"))
+	s.EvictSecrets(0, time.Now().Add(time.Second))
+	s.Replace([]*util.Snippet{{Label: "new"}})
+	unsubscribe()
+	s.Replace(testSnippets())
+
+	assert.Len(t, events, 3)
+	assert.Equal(t, EventSecretUnlocked, events[0].Kind)
+	assert.Equal(t, []string{"pwd"}, events[0].Labels)
+	assert.Equal(t, EventSecretsEvicted, events[1].Kind)
+	assert.Equal(t, []string{"pwd"}, events[1].Labels)
+	assert.Equal(t, EventReplaced, events[2].Kind)
+	assert.Equal(t, "new", events[2].Snippets[0].Label)
+}
+
+func TestSubscriberCanReadStore(t *testing.T) {
+	s := New(testSnippets())
+
+	var labels []string
+	s.Subscribe(func(e Event) {
+		for _, snippet := range s.Snapshot() {
+			labels = append(labels, snippet.Label)
+		}
+	})
+	s.Replace([]*util.Snippet{{Label: "new"}})
+
+	assert.Equal(t, []string{"new"}, labels)
+}
+
+// TestConcurrentAccess exercises the store from several goroutines like the file watcher, secret eviction and UI do.
+// Run with -race to detect unsynchronized access.
+func TestConcurrentAccess(t *testing.T) {
+	s := New(testSnippets())
+
+	var eventsLock sync.Mutex
+	var lastReplaced []*util.Snippet
+	s.Subscribe(func(e Event) {
+		if e.Kind == EventReplaced {
+			eventsLock.Lock()
+			lastReplaced = e.Snippets
+			eventsLock.Unlock()
+		}
+	})
+
+	var final []*util.Snippet
+	var wg sync.WaitGroup
+	run := func(f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				f(i)
+			}
+		}()
+	}
+
+	// File watcher
+	run(func(i int) {
+		snippets := testSnippets()
+		snippets[0].Content = fmt.Sprintf("bar %d", i)
+		s.Replace(snippets)
+		if i == 199 {
+			final = snippets
+		}
+	})
+	// Secret eviction
+	run(func(i int) {
+		s.EvictSecrets(time.Millisecond, time.Now())
+	})
+	// UI
+	run(func(i int) {
+		s.UnlockSecret("pwd", []byte("hunter2"))
+		s.UnlockedSecret("pwd")
+		for _, snippet := range s.Snapshot() {
+			_ = snippet.Label + snippet.Content
+		}
+		s.Find("foo")
+	})
+	run(func(i int) {
+		unsubscribe := s.Subscribe(func(e Event) {})
+		unsubscribe()
+	})
+	wg.Wait()
+
+	eventsLock.Lock()
+	defer eventsLock.Unlock()
+	assert.Equal(t, final, lastReplaced)
+	assert.Equal(t, final, s.Snapshot())
+}
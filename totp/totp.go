@@ -0,0 +1,130 @@
+package totp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+
+	"github.com/sandro-h/snippet/secrets"
+)
+
+// Algorithm is the hash function of the HMAC that generates the codes.
+type Algorithm int
+
+const (
+	// SHA1 is the default, and the only algorithm supported by many authenticator apps.
+	SHA1 Algorithm = iota
+	SHA256
+	SHA512
+)
+
+var algorithmNames = []string{"SHA1", "SHA256", "SHA512"}
+
+func (a Algorithm) String() string {
+	if a < 0 || int(a) >= len(algorithmNames) {
+		return "unknown"
+	}
+	return algorithmNames[a]
+}
+
+// ParseAlgorithm parses the algorithm name, like SHA1. It is case-insensitive.
+func ParseAlgorithm(name string) (Algorithm, error) {
+	for i, n := range algorithmNames {
+		if strings.EqualFold(n, name) {
+			return Algorithm(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown algorithm %s, must be one of: %s", name, strings.Join(algorithmNames, ", "))
+}
+
+func (a Algorithm) hash() func() hash.Hash {
+	switch a {
+	case SHA256:
+		return sha256.New
+	case SHA512:
+		return sha512.New
+	default:
+		return sha1.New
+	}
+}
+
+// Options describe how codes are generated. Services show them next to the seed, if they differ from the defaults.
+type Options struct {
+	// Digits is the length of the codes, 6 to 8.
+	Digits    int
+	Period    time.Duration
+	Algorithm Algorithm
+}
+
+// DefaultOptions are the options used by most services.
+var DefaultOptions = Options{Digits: 6, Period: 30 * time.Second, Algorithm: SHA1}
+
+// Validate checks that codes can be generated with the options.
+func (o Options) Validate() error {
+	if o.Digits < 6 || o.Digits > 8 {
+		return fmt.Errorf("digits must be between 6 and 8, but was %d", o.Digits)
+	}
+	if o.Period < time.Second || o.Period%time.Second != 0 {
+		return fmt.Errorf("period must be a positive number of seconds, but was %s", o.Period)
+	}
+	return nil
+}
+
+// DecodeSeed decodes a base32 seed, as shown by services when setting up 2FA. Spaces, case and padding are ignored.
+// The caller should wipe the seed and the result after use.
+func DecodeSeed(seed []byte) ([]byte, error) {
+	normalized := make([]byte, 0, len(seed))
+	for _, c := range bytes.ToUpper(seed) {
+		if c != ' ' && c != '=' && c != '\n' && c != '\r' && c != '\t' {
+			normalized = append(normalized, c)
+		}
+	}
+	defer secrets.Wipe(normalized)
+
+	key := make([]byte, base32.StdEncoding.WithPadding(base32.NoPadding).DecodedLen(len(normalized)))
+	n, err := base32.StdEncoding.WithPadding(base32.NoPadding).Decode(key, normalized)
+	if err != nil {
+		secrets.Wipe(key)
+		return nil, fmt.Errorf("seed is not base32: %w", err)
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("seed is empty")
+	}
+	return key[:n], nil
+}
+
+// Code returns the RFC 6238 code of the key for the time.
+func Code(key []byte, t time.Time, opts Options) []byte {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/int64(opts.Period/time.Second)))
+
+	mac := hmac.New(opts.Algorithm.hash(), key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	defer secrets.Wipe(sum)
+
+	// Dynamic truncation, see RFC 4226 section 5.3.
+	offset := sum[len(sum)-1] & 0xf
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	code := make([]byte, opts.Digits)
+	for i := len(code) - 1; i >= 0; i-- {
+		code[i] = '0' + byte(value%10)
+		value /= 10
+	}
+	return code
+}
+
+// Remaining returns how long the code for the time stays valid.
+func Remaining(t time.Time, period time.Duration) time.Duration {
+	elapsed := time.Duration(t.UnixNano()) % period
+	return period - elapsed
+}
@@ -0,0 +1,82 @@
+package totp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test vectors from RFC 6238, appendix B.
+func TestCodeRFC6238(t *testing.T) {
+	keys := map[Algorithm][]byte{
+		SHA1:   []byte("12345678901234567890"),
+		SHA256: []byte("12345678901234567890123456789012"),
+		SHA512: []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+	cases := []struct {
+		unix  int64
+		codes map[Algorithm]string
+	}{
+		{59, map[Algorithm]string{SHA1: "94287082", SHA256: "46119246", SHA512: "90693936"}},
+		{1111111109, map[Algorithm]string{SHA1: "07081804", SHA256: "68084774", SHA512: "25091201"}},
+		{1111111111, map[Algorithm]string{SHA1: "14050471", SHA256: "67062674", SHA512: "99943326"}},
+		{1234567890, map[Algorithm]string{SHA1: "89005924", SHA256: "91819424", SHA512: "93441116"}},
+		{2000000000, map[Algorithm]string{SHA1: "69279037", SHA256: "90698825", SHA512: "38618901"}},
+		{20000000000, map[Algorithm]string{SHA1: "65353130", SHA256: "77737706", SHA512: "47863826"}},
+	}
+
+	for _, c := range cases {
+		for alg, want := range c.codes {
+			opts := Options{Digits: 8, Period: 30 * time.Second, Algorithm: alg}
+			assert.Equal(t, want, string(Code(keys[alg], time.Unix(c.unix, 0), opts)), "%s at %d", alg, c.unix)
+		}
+	}
+}
+
+func TestCodeSixDigits(t *testing.T) {
+	code := Code([]byte("12345678901234567890"), time.Unix(1111111109, 0), DefaultOptions)
+
+	assert.Equal(t, "081804", string(code))
+}
+
+func TestDecodeSeed(t *testing.T) {
+	key, err := DecodeSeed([]byte("gezd gnbv gy3t qojq GEZDGNBVGY3TQOJQ"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("12345678901234567890"), key)
+
+	key, err = DecodeSeed([]byte("MFRGG==="))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("abc"), key)
+}
+
+func TestDecodeSeedInvalid(t *testing.T) {
+	_, err := DecodeSeed([]byte("not base32!"))
+	assert.Error(t, err)
+
+	_, err = DecodeSeed([]byte(" "))
+	assert.EqualError(t, err, "seed is empty")
+}
+
+func TestRemaining(t *testing.T) {
+	assert.Equal(t, 30*time.Second, Remaining(time.Unix(60, 0), 30*time.Second))
+	assert.Equal(t, 1*time.Second, Remaining(time.Unix(59, 0), 30*time.Second))
+	assert.Equal(t, 17*time.Second+500*time.Millisecond, Remaining(time.Unix(72, 500000000), 30*time.Second))
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	alg, err := ParseAlgorithm("sha256")
+	assert.Nil(t, err)
+	assert.Equal(t, SHA256, alg)
+
+	_, err = ParseAlgorithm("MD5")
+	assert.EqualError(t, err, "unknown algorithm MD5, must be one of: SHA1, SHA256, SHA512")
+}
+
+func TestValidate(t *testing.T) {
+	assert.Nil(t, DefaultOptions.Validate())
+	assert.Error(t, Options{Digits: 5, Period: 30 * time.Second}.Validate())
+	assert.Error(t, Options{Digits: 6, Period: 0}.Validate())
+	assert.Error(t, Options{Digits: 6, Period: 1500 * time.Millisecond}.Validate())
+}
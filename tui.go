@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/sandro-h/snippet/secrets"
+	"github.com/sandro-h/snippet/tui"
+)
+
+// runTUI lets the user pick a snippet in a full-screen terminal UI and prints the rendered snippet
+// to stdout, e.g. for $(snippet tui). The terminal UI itself is drawn on the TTY, not stdout.
+func runTUI(args []string) error {
+	fs := flag.NewFlagSet("tui", flag.ExitOnError)
+	fs.Parse(args)
+
+	snippets, c, err := loadSnippets()
+	if err != nil {
+		return err
+	}
+
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return err
+	}
+	err = screen.Init()
+	if err != nil {
+		return err
+	}
+
+	picker := tui.NewPicker(screen, snippets)
+	snippet := picker.Pick(strings.Join(fs.Args(), " "))
+	if snippet == nil {
+		screen.Fini()
+		return errCancelled
+	}
+
+	content, err := renderSnippet(snippet, c, picker)
+	defer secrets.Wipe(content)
+	screen.Fini()
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(content)
+	return err
+}
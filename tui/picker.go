@@ -0,0 +1,231 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/sandro-h/snippet/fuzzy"
+	"github.com/sandro-h/snippet/util"
+)
+
+type filteredSnippet struct {
+	snippet            *util.Snippet
+	highlightedLabel   []fuzzy.Segment
+	highlightedContent []fuzzy.Segment
+}
+
+// Picker provides a full-screen terminal UI to fuzzy search a list of snippets and pick one of them.
+// It uses the same fuzzy search and highlighting as the ui.SearchWidget.
+type Picker struct {
+	screen                  tcell.Screen
+	snippets                []*util.Snippet
+	snippetLabels           []string
+	snippetContents         []string
+	filteredSnippets        []*filteredSnippet
+	selectedID              int
+	scrollOffset            int
+	query                   []rune
+	promptStyle             tcell.Style
+	labelStyle              tcell.Style
+	highlightedLabelStyle   tcell.Style
+	contentStyle            tcell.Style
+	highlightedContentStyle tcell.Style
+}
+
+// NewPicker creates a new Picker that draws on the given screen. The screen must already be initialized.
+func NewPicker(screen tcell.Screen, snippets []*util.Snippet) *Picker {
+	p := &Picker{
+		screen:                  screen,
+		promptStyle:             tcell.StyleDefault.Bold(true),
+		labelStyle:              tcell.StyleDefault.Bold(true),
+		highlightedLabelStyle:   tcell.StyleDefault.Bold(true).Foreground(tcell.ColorDodgerBlue),
+		contentStyle:            tcell.StyleDefault.Foreground(tcell.ColorGray),
+		highlightedContentStyle: tcell.StyleDefault.Foreground(tcell.ColorDodgerBlue),
+	}
+	p.SetSnippets(snippets)
+	return p
+}
+
+// SetSnippets sets a new list of snippets for the picker to display.
+func (p *Picker) SetSnippets(snippets []*util.Snippet) {
+	var snippetLabels []string
+	var snippetContents []string
+	for _, s := range snippets {
+		snippetLabels = append(snippetLabels, s.Label)
+		snippetContents = append(snippetContents, strings.ReplaceAll(s.Content, "\n", "\\n"))
+	}
+	p.snippetLabels = snippetLabels
+	p.snippetContents = snippetContents
+	p.snippets = snippets
+	p.filter()
+}
+
+// Pick shows the snippet list and lets the user search and choose a snippet, starting with the given query.
+// It returns nil if the user cancelled.
+func (p *Picker) Pick(query string) *util.Snippet {
+	p.query = []rune(query)
+	p.filter()
+
+	for {
+		p.drawList()
+		switch ev := p.screen.PollEvent().(type) {
+		case nil:
+			return nil
+		case *tcell.EventResize:
+			p.screen.Sync()
+		case *tcell.EventKey:
+			switch ev.Key() {
+			case tcell.KeyDown, tcell.KeyCtrlN:
+				if len(p.filteredSnippets) > 0 {
+					p.selectedID = (p.selectedID + 1) % len(p.filteredSnippets)
+				}
+			case tcell.KeyUp, tcell.KeyCtrlP:
+				if len(p.filteredSnippets) > 0 {
+					p.selectedID = (len(p.filteredSnippets) + p.selectedID - 1) % len(p.filteredSnippets)
+				}
+			case tcell.KeyEnter:
+				if p.selectedID >= 0 && p.selectedID < len(p.filteredSnippets) {
+					return p.filteredSnippets[p.selectedID].snippet
+				}
+			case tcell.KeyEscape, tcell.KeyCtrlC:
+				return nil
+			default:
+				if query, changed := editLine(p.query, ev); changed {
+					p.query = query
+					p.filter()
+				}
+			}
+		}
+	}
+}
+
+// Prompt asks the user to input a value for the given label. If masked is true, the input is not displayed.
+// The second return value is false if the user cancelled.
+func (p *Picker) Prompt(label string, masked bool) (string, bool) {
+	var input []rune
+	for {
+		p.drawPrompt(label, input, masked)
+		switch ev := p.screen.PollEvent().(type) {
+		case nil:
+			return "", false
+		case *tcell.EventResize:
+			p.screen.Sync()
+		case *tcell.EventKey:
+			switch ev.Key() {
+			case tcell.KeyEnter:
+				return string(input), true
+			case tcell.KeyEscape, tcell.KeyCtrlC:
+				return "", false
+			default:
+				input, _ = editLine(input, ev)
+			}
+		}
+	}
+}
+
+func (p *Picker) filter() {
+	matches := fuzzy.SearchFuzzyMulti(string(p.query), p.snippetLabels, p.snippetContents)
+	var filteredSnippets []*filteredSnippet
+
+	for _, m := range matches {
+		filteredSnippets = append(filteredSnippets, &filteredSnippet{
+			snippet:            p.snippets[m.Index],
+			highlightedLabel:   fuzzy.HighlightSegments(p.snippetLabels[m.Index], m.Match1),
+			highlightedContent: fuzzy.HighlightSegments(p.snippetContents[m.Index], m.Match2),
+		})
+	}
+
+	p.filteredSnippets = filteredSnippets
+	p.selectedID = 0
+	p.scrollOffset = 0
+}
+
+func (p *Picker) drawList() {
+	p.screen.Clear()
+	width, height := p.screen.Size()
+
+	x := drawText(p.screen, 0, 0, width, "> ", p.promptStyle)
+	x = drawText(p.screen, x, 0, width, string(p.query), tcell.StyleDefault)
+	p.screen.ShowCursor(x, 0)
+
+	rows := height - 1
+	if p.selectedID < p.scrollOffset {
+		p.scrollOffset = p.selectedID
+	} else if rows > 0 && p.selectedID >= p.scrollOffset+rows {
+		p.scrollOffset = p.selectedID - rows + 1
+	}
+
+	for i := 0; i < rows && p.scrollOffset+i < len(p.filteredSnippets); i++ {
+		id := p.scrollOffset + i
+		fs := p.filteredSnippets[id]
+		selected := id == p.selectedID
+
+		x := 0
+		if selected {
+			x = drawText(p.screen, x, i+1, width, "> ", p.promptStyle)
+		} else {
+			x = drawText(p.screen, x, i+1, width, "  ", tcell.StyleDefault)
+		}
+		x = drawSegments(p.screen, x, i+1, width, fs.highlightedLabel, p.labelStyle, p.highlightedLabelStyle, selected)
+		x = drawText(p.screen, x, i+1, width, " ", tcell.StyleDefault)
+		drawSegments(p.screen, x, i+1, width, fs.highlightedContent, p.contentStyle, p.highlightedContentStyle, selected)
+	}
+
+	p.screen.Show()
+}
+
+func (p *Picker) drawPrompt(label string, input []rune, masked bool) {
+	p.screen.Clear()
+	width, _ := p.screen.Size()
+
+	text := string(input)
+	if masked {
+		text = strings.Repeat("*", len(input))
+	}
+
+	x := drawText(p.screen, 0, 0, width, label+": ", p.promptStyle)
+	x = drawText(p.screen, x, 0, width, text, tcell.StyleDefault)
+	p.screen.ShowCursor(x, 0)
+	p.screen.Show()
+}
+
+func editLine(line []rune, ev *tcell.EventKey) ([]rune, bool) {
+	switch ev.Key() {
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if len(line) > 0 {
+			return line[:len(line)-1], true
+		}
+	case tcell.KeyCtrlU:
+		return nil, len(line) > 0
+	case tcell.KeyRune:
+		return append(line, ev.Rune()), true
+	}
+	return line, false
+}
+
+func drawSegments(screen tcell.Screen, x int, y int, maxX int, segments []fuzzy.Segment, style tcell.Style, highlightedStyle tcell.Style, selected bool) int {
+	for _, s := range segments {
+		segStyle := style
+		if s.Highlighted {
+			segStyle = highlightedStyle
+		}
+		if selected {
+			segStyle = segStyle.Reverse(true)
+		}
+		x = drawText(screen, x, y, maxX, s.Str, segStyle)
+	}
+	return x
+}
+
+func drawText(screen tcell.Screen, x int, y int, maxX int, text string, style tcell.Style) int {
+	for _, r := range text {
+		w := runewidth.RuneWidth(r)
+		if x+w > maxX {
+			break
+		}
+		screen.SetContent(x, y, r, nil, style)
+		x += w
+	}
+	return x
+}
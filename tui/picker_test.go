@@ -0,0 +1,110 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/sandro-h/snippet/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPicker(t *testing.T) (*Picker, tcell.SimulationScreen) {
+	screen := tcell.NewSimulationScreen("UTF-8")
+	err := screen.Init()
+	assert.Nil(t, err)
+	screen.SetSize(80, 10)
+
+	snippets := []*util.Snippet{
+		{Label: "foo", Content: "bar"},
+		{Label: "docker bash", Content: "docker exec -ti {container} bash"},
+		{Label: "lorem", Content: "Lorem ipsum\ndolor sit amet"},
+	}
+	return NewPicker(screen, snippets), screen
+}
+
+// inject posts the runes of str followed by the keys to the screen. The screen's event queue is small,
+// so this happens in the background while the picker consumes the events.
+func inject(screen tcell.Screen, str string, keys ...tcell.Key) {
+	go func() {
+		for _, r := range str {
+			screen.PostEventWait(tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+		}
+		for _, k := range keys {
+			screen.PostEventWait(tcell.NewEventKey(k, 0, tcell.ModNone))
+		}
+	}()
+}
+
+func TestPickFirstMatch(t *testing.T) {
+	picker, screen := newTestPicker(t)
+	defer screen.Fini()
+
+	inject(screen, "dock", tcell.KeyEnter)
+
+	picked := picker.Pick("")
+	assert.Equal(t, "docker bash", picked.Label)
+}
+
+func TestPickNavigate(t *testing.T) {
+	picker, screen := newTestPicker(t)
+	defer screen.Fini()
+
+	inject(screen, "", tcell.KeyDown, tcell.KeyDown, tcell.KeyUp, tcell.KeyEnter)
+
+	picked := picker.Pick("")
+	assert.Equal(t, "docker bash", picked.Label)
+}
+
+func TestPickInitialQuery(t *testing.T) {
+	picker, screen := newTestPicker(t)
+	defer screen.Fini()
+
+	inject(screen, "", tcell.KeyEnter)
+
+	picked := picker.Pick("lorem")
+	assert.Equal(t, "lorem", picked.Label)
+}
+
+func TestPickCancel(t *testing.T) {
+	picker, screen := newTestPicker(t)
+	defer screen.Fini()
+
+	inject(screen, "foo", tcell.KeyEscape)
+
+	assert.Nil(t, picker.Pick(""))
+}
+
+func TestPickHighlightsMatches(t *testing.T) {
+	picker, screen := newTestPicker(t)
+	defer screen.Fini()
+
+	inject(screen, "", tcell.KeyEscape)
+	picker.Pick("foo")
+
+	cells, width, _ := screen.GetContents()
+	label := cells[width+2]
+	assert.Equal(t, []rune("f"), label.Runes)
+	fg, _, _ := label.Style.Decompose()
+	assert.Equal(t, tcell.ColorDodgerBlue, fg)
+}
+
+func TestPrompt(t *testing.T) {
+	picker, screen := newTestPicker(t)
+	defer screen.Fini()
+
+	inject(screen, "my-containerx", tcell.KeyBackspace2, tcell.KeyEnter)
+
+	val, ok := picker.Prompt("container", false)
+	assert.True(t, ok)
+	assert.Equal(t, "my-container", val)
+}
+
+func TestPromptCancel(t *testing.T) {
+	picker, screen := newTestPicker(t)
+	defer screen.Fini()
+
+	inject(screen, "secret", tcell.KeyEscape)
+
+	_, ok := picker.Prompt("Password", true)
+	assert.False(t, ok)
+}
@@ -0,0 +1,84 @@
+package robot
+
+/*
+#cgo LDFLAGS: -lX11 -lXtst
+#include <unistd.h>
+#include <X11/Xlib.h>
+#include <X11/extensions/XTest.h>
+
+static int has_keysym(unsigned long sym) {
+	Display *dpy = XOpenDisplay(NULL);
+	if (!dpy) {
+		return 0;
+	}
+	KeyCode code = XKeysymToKeycode(dpy, sym);
+	XCloseDisplay(dpy);
+	return code != 0;
+}
+
+// remap_keysym maps the keysym to a keycode without any keysyms, types it and unmaps it again.
+// Returns -1 if the display cannot be opened and -2 if there is no unused keycode.
+static int remap_keysym(unsigned long sym) {
+	Display *dpy = XOpenDisplay(NULL);
+	if (!dpy) {
+		return -1;
+	}
+
+	int min, max, per;
+	XDisplayKeycodes(dpy, &min, &max);
+	KeySym *map = XGetKeyboardMapping(dpy, min, max - min + 1, &per);
+	int unused = 0;
+	for (int code = max; code >= min && !unused; code--) {
+		int empty = 1;
+		for (int i = 0; i < per; i++) {
+			if (map[(code - min) * per + i] != NoSymbol) {
+				empty = 0;
+				break;
+			}
+		}
+		if (empty) {
+			unused = code;
+		}
+	}
+	XFree(map);
+	if (!unused) {
+		XCloseDisplay(dpy);
+		return -2;
+	}
+
+	KeySym syms[2] = {sym, sym};
+	XChangeKeyboardMapping(dpy, unused, 2, syms, 1);
+	XSync(dpy, False);
+	// Give the apps time to pick up the changed mapping.
+	usleep(20000);
+
+	XTestFakeKeyEvent(dpy, unused, True, CurrentTime);
+	XTestFakeKeyEvent(dpy, unused, False, CurrentTime);
+	XSync(dpy, False);
+	// Give the focused app time to process the key before the mapping is removed again.
+	usleep(50000);
+
+	KeySym none[2] = {NoSymbol, NoSymbol};
+	XChangeKeyboardMapping(dpy, unused, 2, none, 1);
+	XSync(dpy, False);
+	XCloseDisplay(dpy);
+	return 0;
+}
+*/
+import "C"
+
+import "errors"
+
+func hasKeySym(keySym uint32) bool {
+	return C.has_keysym(C.ulong(keySym)) != 0
+}
+
+func remapKeySym(keySym uint32) error {
+	switch C.remap_keysym(C.ulong(keySym)) {
+	case -1:
+		return errors.New("cannot open X display")
+	case -2:
+		return errors.New("no unused key to map")
+	}
+	return nil
+}
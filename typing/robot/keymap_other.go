@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package robot
+
+import "errors"
+
+// The keymap functions are only needed on Linux, robotgo can type all characters on other OSes.
+
+func hasKeySym(keySym uint32) bool {
+	return false
+}
+
+func remapKeySym(keySym uint32) error {
+	return errors.New("remapping keys is only supported on Linux")
+}
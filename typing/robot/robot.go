@@ -0,0 +1,96 @@
+package robot
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/go-vgo/robotgo"
+	"github.com/go-vgo/robotgo/clipboard"
+)
+
+// Backend simulates key presses with robotgo.
+type Backend struct{}
+
+// TypeStr types the string with robotgo.
+func (Backend) TypeStr(str string) {
+	robotgo.TypeStr(str)
+}
+
+// KeyTap taps the key with robotgo.
+func (Backend) KeyTap(key string, modifiers ...string) {
+	if len(modifiers) == 0 {
+		robotgo.KeyTap(key)
+	} else {
+		robotgo.KeyTap(key, modifiers)
+	}
+}
+
+// HasKeySym returns whether the X keysym is on the active keymap.
+func (Backend) HasKeySym(keySym uint32) bool {
+	return hasKeySym(keySym)
+}
+
+// TypeKeySym types the X keysym with robotgo.
+func (Backend) TypeKeySym(keySym uint32) {
+	robotgo.KeysymType(keySym)
+}
+
+// RemapKeySym temporarily maps the X keysym to an unused keycode and types it.
+func (Backend) RemapKeySym(keySym uint32) error {
+	return remapKeySym(keySym)
+}
+
+// HexInputAvailable guesses whether the input method supports Ctrl+Shift+U hex input. IBus and fcitx
+// support it in all apps.
+func (Backend) HexInputAvailable() bool {
+	for _, env := range []string{"GTK_IM_MODULE", "QT_IM_MODULE", "XMODIFIERS"} {
+		val := os.Getenv(env)
+		if strings.Contains(val, "ibus") || strings.Contains(val, "fcitx") {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadClipboard reads the clipboard.
+func (Backend) ReadClipboard() (string, error) {
+	return clipboard.ReadAll()
+}
+
+// WriteClipboard writes the clipboard.
+func (Backend) WriteClipboard(str string) error {
+	return clipboard.WriteAll(str)
+}
+
+// WritePrimary sets the PRIMARY selection with xclip or xsel, which robotgo also uses for the clipboard.
+func (Backend) WritePrimary(str string) error {
+	if runtime.GOOS != "linux" {
+		return errors.New("the PRIMARY selection is only supported on Linux")
+	}
+
+	cmd := exec.Command("xclip", "-in", "-selection", "primary")
+	if _, err := exec.LookPath("xclip"); err != nil {
+		cmd = exec.Command("xsel", "--input", "--primary")
+	}
+	cmd.Stdin = strings.NewReader(str)
+	return cmd.Run()
+}
+
+// StartTyping keeps robotgo's display connection open until EndTyping.
+func (Backend) StartTyping() {
+	robotgo.StartMultiToggleKey()
+}
+
+// EndTyping closes robotgo's display connection.
+func (Backend) EndTyping() {
+	robotgo.EndMultiToggleKey()
+}
+
+// Sleep sleeps with robotgo.
+func (Backend) Sleep(d time.Duration) {
+	robotgo.MicroSleep(float64(d.Milliseconds()))
+}
@@ -1,11 +1,15 @@
 package typing
 
 import (
+	"bytes"
+	"context"
+	"log"
 	"runtime"
-	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
-	"github.com/go-vgo/robotgo"
-	"github.com/go-vgo/robotgo/clipboard"
+	"github.com/sandro-h/snippet/secrets"
 	"github.com/sandro-h/snippet/util"
 )
 
@@ -16,78 +20,247 @@ type SpecialChar struct {
 	KeySym     int    `yaml:"key_sym"`
 }
 
+// DefaultLineDelay is the delay before typing the next line of a snippet.
+const DefaultLineDelay = 100 * time.Millisecond
+
+// Without a typing delay, lines are still typed in chunks of this size, so typing can be aborted quickly.
+const abortCheckSize = 16
+
 // Config specifies the behavior when typing.
 type Config struct {
 	SpecialChars    map[string]SpecialChar
 	SpecialCharList string
+	// TypingDelay is the delay between chunks of ChunkSize characters. If zero, lines are typed at once.
+	TypingDelay time.Duration
+	// LineDelay is the delay before typing the next line.
+	LineDelay time.Duration
+	// ChunkSize is the number of characters typed before waiting TypingDelay. Values below 1 mean 1.
+	ChunkSize int
+}
+
+// With returns a copy of the config with the snippet's typing options applied.
+func (c *Config) With(opts util.TypingOptions) *Config {
+	res := *c
+	if opts.TypingDelay != nil {
+		res.TypingDelay = *opts.TypingDelay
+	}
+	if opts.LineDelay != nil {
+		res.LineDelay = *opts.LineDelay
+	}
+	if opts.ChunkSize != nil {
+		res.ChunkSize = *opts.ChunkSize
+	}
+	return &res
+}
+
+// Backend simulates key presses and accesses the clipboard.
+type Backend interface {
+	// TypeStr types a string of characters that are on the keyboard layout.
+	TypeStr(str string)
+	// KeyTap presses and releases the key while holding down the modifiers.
+	KeyTap(key string, modifiers ...string)
+	// HasKeySym returns whether the X keysym is on the active keymap.
+	HasKeySym(keySym uint32) bool
+	// TypeKeySym types the X keysym. It must be on the active keymap.
+	TypeKeySym(keySym uint32)
+	// RemapKeySym temporarily maps the X keysym to an unused key and types it. It returns an error
+	// if that is not possible, e.g. because there is no unused key.
+	RemapKeySym(keySym uint32) error
+	// HexInputAvailable returns whether characters can be entered with Ctrl+Shift+U and their hex code.
+	HexInputAvailable() bool
+	ReadClipboard() (string, error)
+	WriteClipboard(str string) error
+	// WritePrimary sets the X11 PRIMARY selection.
+	WritePrimary(str string) error
+	// StartTyping and EndTyping surround typing a string, so the backend can keep resources open in between.
+	StartTyping()
+	EndTyping()
+	Sleep(d time.Duration)
+}
+
+var backend Backend
+
+// SetBackend sets the backend that simulates the key presses. It must be called before typing any snippet.
+func SetBackend(b Backend) {
+	backend = b
+}
+
+// running is the snippet being typed by TypeSnippetAsync.
+var running struct {
+	lock   sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// TypeSnippetAsync types the snippet on a new goroutine, so it can be stopped with Abort.
+// A snippet that is still being typed is aborted first, so their key presses don't get mixed up.
+// onTyped is called after the whole snippet was typed, unless it is nil.
+// TypeSnippetAsync takes ownership of the content and wipes it once it is typed or aborted, since it may be a secret.
+func TypeSnippetAsync(content []byte, copy util.CopyMode, cfg *Config, onTyped func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	running.lock.Lock()
+	if running.cancel != nil {
+		running.cancel()
+	}
+	running.ctx, running.cancel = ctx, cancel
+	running.lock.Unlock()
+
+	go func() {
+		if err := TypeSnippet(ctx, content, copy, cfg); err != nil {
+			log.Printf("Stopped typing snippet: %s", err)
+		} else if onTyped != nil {
+			onTyped()
+		}
+		secrets.Wipe(content)
+
+		running.lock.Lock()
+		if running.ctx == ctx {
+			running.ctx, running.cancel = nil, nil
+		}
+		running.lock.Unlock()
+		cancel()
+	}()
+}
+
+// Abort stops typing the snippet started with TypeSnippetAsync. It returns false if no snippet is being typed.
+func Abort() bool {
+	running.lock.Lock()
+	defer running.lock.Unlock()
+	if running.cancel == nil {
+		return false
+	}
+	running.cancel()
+	running.ctx, running.cancel = nil, nil
+	return true
 }
 
 // TypeSnippet types the snippet content by simulating key presses if copy=false, or simulating a copy/paste if copy=true.
-func TypeSnippet(content string, copy util.CopyMode, cfg *Config) {
+// It stops between lines and chunks of characters when ctx is done and returns ctx's error.
+// The content is passed as bytes so callers can wipe secrets afterwards. Only small chunks of it are
+// converted to strings for the backend.
+func TypeSnippet(ctx context.Context, content []byte, copy util.CopyMode, cfg *Config) error {
 	switch copy {
 	case util.CopyModeNormal:
-		copyPasteSnippet(content)
-		break
+		return copyPasteSnippet(ctx, content)
 	case util.CopyModeShell:
-		copyPasteSnippetToShell(content)
-		break
+		return copyPasteSnippetToShell(ctx, content)
+	case util.CopyModeClipboardOnly:
+		return backend.WriteClipboard(string(content))
 	default:
-		typeSnippet(content, cfg)
+		return typeSnippet(ctx, content, cfg)
 	}
 }
 
-func copyPasteSnippet(content string) {
-	robotgo.MicroSleep(50)
-	robotgo.PasteStr(content)
+// WritePrimary sets the X11 PRIMARY selection, so the content can be pasted with a middle click.
+func WritePrimary(content []byte) error {
+	return backend.WritePrimary(string(content))
+}
+
+func copyPasteSnippet(ctx context.Context, content []byte) error {
+	backend.Sleep(50 * time.Millisecond)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	backend.WriteClipboard(string(content))
+	paste()
+	return nil
 }
 
-func copyPasteSnippetToShell(content string) {
-	robotgo.MicroSleep(50)
-	clipboard.WriteAll(content)
+func copyPasteSnippetToShell(ctx context.Context, content []byte) error {
+	backend.Sleep(50 * time.Millisecond)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	backend.WriteClipboard(string(content))
 
 	if runtime.GOOS == "darwin" {
-		robotgo.KeyTap("v", "shift", "command")
+		backend.KeyTap("v", "shift", "command")
 	}
 
-	robotgo.KeyTap("v", "shift", "control")
+	backend.KeyTap("v", "shift", "control")
+	return nil
+}
+
+func paste() {
+	if runtime.GOOS == "darwin" {
+		backend.KeyTap("v", "command")
+	} else {
+		backend.KeyTap("v", "control")
+	}
 }
 
-func typeSnippet(content string, cfg *Config) {
-	lines := strings.Split(content, "\n")
+func typeSnippet(ctx context.Context, content []byte, cfg *Config) error {
+	lines := bytes.Split(content, []byte("\n"))
 	first := true
 	for _, l := range lines {
 		if !first {
-			robotgo.MicroSleep(100)
-			robotgo.KeyTap("enter")
+			backend.Sleep(cfg.LineDelay)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			backend.KeyTap("enter")
+		}
+		err := typeChunked(ctx, l, cfg)
+		if err != nil {
+			return err
 		}
-		typeStr(l, cfg)
 		first = false
 	}
+	return nil
+}
+
+// typeChunked types the line in chunks of cfg.ChunkSize characters with cfg.TypingDelay in between,
+// so slow targets like remote desktops don't drop characters. Without a typing delay, the chunks
+// are only used to check whether ctx is done.
+func typeChunked(ctx context.Context, line []byte, cfg *Config) error {
+	size := cfg.ChunkSize
+	if cfg.TypingDelay <= 0 {
+		size = abortCheckSize
+	} else if size < 1 {
+		size = 1
+	}
+
+	for start := 0; start < len(line); {
+		if start > 0 && cfg.TypingDelay > 0 {
+			backend.Sleep(cfg.TypingDelay)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		end := start
+		for n := 0; n < size && end < len(line); n++ {
+			_, runeSize := utf8.DecodeRune(line[end:])
+			end += runeSize
+		}
+		typeStr(string(line[start:end]), cfg)
+		start = end
+	}
+	return nil
 }
 
 func typeStr(str string, cfg *Config) {
 	// robotgo's linux implementation for typing cannot deal with special keys on non-standard keyboard layouts (e.g. Swiss German),
-	// so handle such special keys explicitly.
+	// or characters that are not on the keyboard layout at all, so handle such characters explicitly.
 	if runtime.GOOS == "linux" {
-		robotgo.StartMultiToggleKey()
+		backend.StartTyping()
 		parts := util.SplitSpecials(str, cfg.SpecialCharList)
 		for _, p := range parts {
-			if len(p) == 1 && strings.Contains(cfg.SpecialCharList, p) {
-				typeSpecialKey(cfg.SpecialChars[p])
+			if special, ok := cfg.SpecialChars[p]; ok {
+				typeSpecialKey(special)
 			} else {
-				robotgo.TypeStr(p)
+				typeWithFallback(p)
 			}
 		}
-		robotgo.EndMultiToggleKey()
+		backend.EndTyping()
 	} else {
-		robotgo.TypeStr(str)
+		backend.TypeStr(str)
 	}
 }
 
 func typeSpecialKey(key SpecialChar) {
-	robotgo.KeysymType(uint32(key.KeySym))
+	backend.TypeKeySym(uint32(key.KeySym))
 
 	if key.SpaceAfter {
-		robotgo.KeyTap("space")
+		backend.KeyTap("space")
 	}
 }
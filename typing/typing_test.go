@@ -0,0 +1,300 @@
+package typing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sandro-h/snippet/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBackend records the simulated key presses.
+type fakeBackend struct {
+	calls     []string
+	keymap    map[uint32]bool
+	canRemap  bool
+	hexInput  bool
+	clipboard string
+	// recordSleeps also records the delays, which most tests don't care about.
+	recordSleeps bool
+	// onSleep is called on every delay, e.g. to abort typing mid-way.
+	onSleep func()
+}
+
+func useFakeBackend() *fakeBackend {
+	b := &fakeBackend{keymap: map[uint32]bool{}, clipboard: "previous"}
+	SetBackend(b)
+	return b
+}
+
+func (b *fakeBackend) TypeStr(str string) {
+	b.calls = append(b.calls, "type "+str)
+}
+
+func (b *fakeBackend) KeyTap(key string, modifiers ...string) {
+	b.calls = append(b.calls, "tap "+strings.Join(append(modifiers, key), "+"))
+}
+
+func (b *fakeBackend) HasKeySym(keySym uint32) bool {
+	return b.keymap[keySym]
+}
+
+func (b *fakeBackend) TypeKeySym(keySym uint32) {
+	b.calls = append(b.calls, fmt.Sprintf("keysym %#x", keySym))
+}
+
+func (b *fakeBackend) RemapKeySym(keySym uint32) error {
+	if !b.canRemap {
+		return errors.New("no unused key")
+	}
+	b.calls = append(b.calls, fmt.Sprintf("remap %#x", keySym))
+	return nil
+}
+
+func (b *fakeBackend) HexInputAvailable() bool {
+	return b.hexInput
+}
+
+func (b *fakeBackend) ReadClipboard() (string, error) {
+	return b.clipboard, nil
+}
+
+func (b *fakeBackend) WriteClipboard(str string) error {
+	b.clipboard = str
+	b.calls = append(b.calls, "clipboard "+str)
+	return nil
+}
+
+func (b *fakeBackend) WritePrimary(str string) error {
+	b.calls = append(b.calls, "primary "+str)
+	return nil
+}
+
+func (b *fakeBackend) StartTyping() {}
+
+func (b *fakeBackend) EndTyping() {}
+
+func (b *fakeBackend) Sleep(d time.Duration) {
+	if b.onSleep != nil {
+		b.onSleep()
+	}
+	if b.recordSleeps {
+		b.calls = append(b.calls, "sleep "+d.String())
+	}
+}
+
+func TestTypeASCII(t *testing.T) {
+	b := useFakeBackend()
+
+	typeWithFallback("hello world")
+
+	assert.Equal(t, []string{"type hello world"}, b.calls)
+}
+
+func TestTypeKeySymOnKeymap(t *testing.T) {
+	b := useFakeBackend()
+	b.keymap[0xe9] = true
+	b.keymap[0x20ac] = true
+
+	typeWithFallback("café 5€")
+
+	assert.Equal(t, []string{"type caf", "keysym 0xe9", "type  5", "keysym 0x20ac"}, b.calls)
+}
+
+func TestTypeRemap(t *testing.T) {
+	b := useFakeBackend()
+	b.canRemap = true
+
+	typeWithFallback("a😀")
+
+	assert.Equal(t, []string{"type a", "remap 0x101f600"}, b.calls)
+}
+
+func TestTypeHexInput(t *testing.T) {
+	b := useFakeBackend()
+	b.hexInput = true
+
+	typeWithFallback("日")
+
+	assert.Equal(t, []string{"tap ctrl+shift+u", "type 65e5", "tap space"}, b.calls)
+}
+
+func TestTypeClipboardFallback(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("pastes with Cmd+V on macOS")
+	}
+	b := useFakeBackend()
+
+	typeWithFallback("日")
+
+	assert.Equal(t, []string{"clipboard 日", "tap control+v", "clipboard previous"}, b.calls)
+}
+
+func TestTypeSnippetSpecialChars(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("special chars are only used on Linux")
+	}
+	b := useFakeBackend()
+	cfg := &Config{
+		SpecialChars:    map[string]SpecialChar{"~": {Character: "~", KeySym: 0xfe53, SpaceAfter: true}},
+		SpecialCharList: "~",
+	}
+
+	TypeSnippet(context.Background(), []byte("cd ~\nls"), util.CopyModeNone, cfg)
+
+	assert.Equal(t, []string{"type cd ", "keysym 0xfe53", "tap space", "tap enter", "type ls"}, b.calls)
+}
+
+func TestTypeSnippetCopyShell(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("also pastes with Cmd+Shift+V on macOS")
+	}
+	b := useFakeBackend()
+
+	TypeSnippet(context.Background(), []byte("echo hi"), util.CopyModeShell, &Config{})
+
+	assert.Equal(t, []string{"clipboard echo hi", "tap shift+control+v"}, b.calls)
+}
+
+func TestTypeSnippetChunked(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("types each chunk with robotgo on other OSes")
+	}
+	b := useFakeBackend()
+	b.recordSleeps = true
+	cfg := &Config{TypingDelay: 20 * time.Millisecond, LineDelay: 300 * time.Millisecond, ChunkSize: 2}
+
+	TypeSnippet(context.Background(), []byte("abcde\nf"), util.CopyModeNone, cfg)
+
+	assert.Equal(t, []string{
+		"type ab", "sleep 20ms", "type cd", "sleep 20ms", "type e",
+		"sleep 300ms", "tap enter", "type f",
+	}, b.calls)
+}
+
+func TestTypeSnippetUnchunked(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("types with robotgo on other OSes")
+	}
+	b := useFakeBackend()
+	b.recordSleeps = true
+
+	TypeSnippet(context.Background(), []byte("abc\nd"), util.CopyModeNone, &Config{LineDelay: DefaultLineDelay})
+
+	assert.Equal(t, []string{"type abc", "sleep 100ms", "tap enter", "type d"}, b.calls)
+}
+
+func TestConfigWith(t *testing.T) {
+	delay := 50 * time.Millisecond
+	size := 3
+	cfg := &Config{TypingDelay: 10 * time.Millisecond, LineDelay: DefaultLineDelay, ChunkSize: 1}
+
+	res := cfg.With(util.TypingOptions{TypingDelay: &delay, ChunkSize: &size})
+
+	assert.Equal(t, &Config{TypingDelay: delay, LineDelay: DefaultLineDelay, ChunkSize: 3}, res)
+	assert.Equal(t, 10*time.Millisecond, cfg.TypingDelay)
+}
+
+func TestTypeSnippetStopsWhenCancelled(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("types each chunk with robotgo on other OSes")
+	}
+	b := useFakeBackend()
+	ctx, cancel := context.WithCancel(context.Background())
+	sleeps := 0
+	b.onSleep = func() {
+		sleeps++
+		if sleeps == 2 {
+			cancel()
+		}
+	}
+
+	err := TypeSnippet(ctx, []byte("abcdef\nghi"), util.CopyModeNone, &Config{TypingDelay: time.Millisecond, ChunkSize: 2})
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, []string{"type ab", "type cd"}, b.calls)
+}
+
+func TestTypeSnippetLongLineWithoutDelayCanBeCancelled(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("types each chunk with robotgo on other OSes")
+	}
+	b := useFakeBackend()
+	line := strings.Repeat("a", abortCheckSize+1)
+
+	TypeSnippet(context.Background(), []byte(line), util.CopyModeNone, &Config{})
+
+	assert.Equal(t, []string{"type " + line[:abortCheckSize], "type a"}, b.calls)
+}
+
+func TestTypeSnippetCancelledBeforePaste(t *testing.T) {
+	b := useFakeBackend()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := TypeSnippet(ctx, []byte("echo hi"), util.CopyModeNormal, &Config{})
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Empty(t, b.calls)
+}
+
+func TestAbort(t *testing.T) {
+	b := useFakeBackend()
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	first := true
+	b.onSleep = func() {
+		if first {
+			first = false
+			close(started)
+			<-unblock
+		}
+	}
+
+	assert.False(t, Abort())
+	TypeSnippetAsync([]byte("abc"), util.CopyModeNone, &Config{TypingDelay: time.Millisecond, ChunkSize: 1}, nil)
+	<-started
+	assert.True(t, Abort())
+	assert.False(t, Abort())
+	close(unblock)
+
+	assert.Eventually(t, func() bool {
+		running.lock.Lock()
+		defer running.lock.Unlock()
+		return running.ctx == nil
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"type a"}, b.calls)
+}
+
+func TestTypeSnippetClipboardOnly(t *testing.T) {
+	b := useFakeBackend()
+
+	err := TypeSnippet(context.Background(), []byte("echo hi"), util.CopyModeClipboardOnly, &Config{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"clipboard echo hi"}, b.calls)
+}
+
+func TestTypeSnippetAsyncWipesContent(t *testing.T) {
+	useFakeBackend()
+	content := []byte("hunter2")
+	typed := make(chan struct{})
+
+	TypeSnippetAsync(content, util.CopyModeNormal, &Config{}, func() {
+		assert.Equal(t, "hunter2", string(content))
+		close(typed)
+	})
+
+	<-typed
+	assert.Eventually(t, func() bool {
+		running.lock.Lock()
+		defer running.lock.Unlock()
+		return running.ctx == nil
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, make([]byte, 7), content)
+}
@@ -0,0 +1,56 @@
+package typing
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"unicode/utf8"
+
+	"github.com/sandro-h/snippet/util"
+)
+
+// Insert describes what typing a snippet inserted into the focused app, so it can be undone.
+type Insert struct {
+	Copy util.CopyMode `yaml:"copy"`
+	// Chars is the number of characters of the snippet.
+	Chars int `yaml:"chars"`
+	Lines int `yaml:"lines"`
+}
+
+// NewInsert returns the Insert for typing the content.
+func NewInsert(content []byte, copy util.CopyMode) Insert {
+	return Insert{
+		Copy:  copy,
+		Chars: utf8.RuneCount(content),
+		Lines: bytes.Count(content, []byte("\n")) + 1,
+	}
+}
+
+// Undo removes what was inserted from the focused app. Content pasted with Ctrl+V is removed with the
+// app's undo shortcut, other single-line content with backspaces. Content pasted into a shell is not
+// undone with Ctrl+Z, because that suspends the shell's foreground job.
+func Undo(insert Insert) error {
+	if insert.Copy == util.CopyModeNormal {
+		if runtime.GOOS == "darwin" {
+			backend.KeyTap("z", "command")
+		} else {
+			backend.KeyTap("z", "control")
+		}
+		return nil
+	}
+
+	if insert.Copy == util.CopyModeClipboardOnly {
+		return errors.New("the snippet was only copied to the clipboard")
+	}
+
+	if insert.Lines > 1 {
+		return errors.New("cannot undo a snippet with several lines that was typed or pasted into a shell")
+	}
+
+	backend.StartTyping()
+	for i := 0; i < insert.Chars; i++ {
+		backend.KeyTap("backspace")
+	}
+	backend.EndTyping()
+	return nil
+}
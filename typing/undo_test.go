@@ -0,0 +1,44 @@
+package typing
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/sandro-h/snippet/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewInsert(t *testing.T) {
+	assert.Equal(t, Insert{Copy: util.CopyModeShell, Chars: 4, Lines: 1}, NewInsert([]byte("café"), util.CopyModeShell))
+	assert.Equal(t, Insert{Copy: util.CopyModeNone, Chars: 3, Lines: 2}, NewInsert([]byte("a\nb"), util.CopyModeNone))
+}
+
+func TestUndoTyped(t *testing.T) {
+	b := useFakeBackend()
+
+	err := Undo(NewInsert([]byte("café"), util.CopyModeNone))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"tap backspace", "tap backspace", "tap backspace", "tap backspace"}, b.calls)
+}
+
+func TestUndoPasted(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("undoes with Cmd+Z on macOS")
+	}
+	b := useFakeBackend()
+
+	err := Undo(NewInsert([]byte("line 1\nline 2"), util.CopyModeNormal))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"tap control+z"}, b.calls)
+}
+
+func TestUndoTypedMultiLine(t *testing.T) {
+	b := useFakeBackend()
+
+	err := Undo(NewInsert([]byte("ls\npwd"), util.CopyModeShell))
+
+	assert.Error(t, err)
+	assert.Empty(t, b.calls)
+}
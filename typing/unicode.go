@@ -0,0 +1,106 @@
+package typing
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Keysyms of characters that have a legacy keysym outside of Latin-1, which keymaps use instead of
+// the Unicode keysym.
+var legacyKeySyms = map[rune]uint32{
+	'€': 0x20ac,
+}
+
+// typeWithFallback types the string. ASCII characters are typed directly, other characters with
+// the first method that works:
+//
+//  1. the character's keysym, if it is on the keymap
+//  2. temporarily mapping the keysym to an unused key
+//  3. Ctrl+Shift+U and the character's hex code, if an input method supports it
+//  4. pasting the character from the clipboard
+func typeWithFallback(str string) {
+	var ascii strings.Builder
+	for _, r := range str {
+		if r < 0x80 {
+			ascii.WriteRune(r)
+			continue
+		}
+
+		if ascii.Len() > 0 {
+			backend.TypeStr(ascii.String())
+			ascii.Reset()
+		}
+		typeRune(r)
+	}
+
+	if ascii.Len() > 0 {
+		backend.TypeStr(ascii.String())
+	}
+}
+
+func typeRune(r rune) {
+	if keySym, ok := keySymOnKeymap(r); ok {
+		backend.TypeKeySym(keySym)
+		return
+	}
+
+	err := backend.RemapKeySym(unicodeKeySym(r))
+	if err == nil {
+		return
+	}
+	log.Printf("Could not type %q by remapping a key: %s", r, err)
+
+	if backend.HexInputAvailable() {
+		backend.KeyTap("u", "ctrl", "shift")
+		backend.TypeStr(fmt.Sprintf("%x", r))
+		backend.KeyTap("space")
+		return
+	}
+
+	pasteRune(r)
+}
+
+// keySymOnKeymap returns the keysym for the character, if the keymap has it.
+func keySymOnKeymap(r rune) (uint32, bool) {
+	candidates := []uint32{unicodeKeySym(r)}
+	if keySym, ok := legacyKeySyms[r]; ok {
+		candidates = append(candidates, keySym)
+	}
+	for _, keySym := range candidates {
+		if backend.HasKeySym(keySym) {
+			return keySym, true
+		}
+	}
+	return 0, false
+}
+
+// unicodeKeySym returns the X keysym for the character. Latin-1 characters have keysyms equal to their
+// code point, all others have the code point with the 0x01000000 bit set.
+func unicodeKeySym(r rune) uint32 {
+	if r >= 0xa0 && r <= 0xff {
+		return uint32(r)
+	}
+	return 0x01000000 | uint32(r)
+}
+
+// pasteRune pastes the character from the clipboard. The previous clipboard content is restored afterwards.
+func pasteRune(r rune) {
+	previous, err := backend.ReadClipboard()
+	if err != nil {
+		log.Printf("Could not type %q: %s", r, err)
+		return
+	}
+
+	err = backend.WriteClipboard(string(r))
+	if err != nil {
+		log.Printf("Could not type %q: %s", r, err)
+		return
+	}
+	paste()
+
+	// Give the app time to read the clipboard before restoring it.
+	backend.Sleep(50 * time.Millisecond)
+	backend.WriteClipboard(previous)
+}
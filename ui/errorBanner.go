@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// ErrorBanner shows a dismissable list of errors, e.g. problems loading the snippets file.
+type ErrorBanner struct {
+	Container *fyne.Container
+	text      *widget.RichText
+	lock      sync.Mutex
+	sources   []string
+	errors    map[string][]string
+}
+
+// NewErrorBanner creates a new ErrorBanner. It is hidden as long as there are no errors.
+// onDismiss is called after the user dismissed the banner.
+func NewErrorBanner(onDismiss func()) *ErrorBanner {
+	b := &ErrorBanner{
+		text:   widget.NewRichText(),
+		errors: make(map[string][]string),
+	}
+	b.text.Wrapping = fyne.TextWrapWord
+
+	dismiss := widget.NewButtonWithIcon("", theme.CancelIcon(), func() {
+		b.Container.Hide()
+		onDismiss()
+	})
+	b.Container = container.NewBorder(nil, nil, nil, container.NewVBox(dismiss), b.text)
+	b.Container.Hide()
+	return b
+}
+
+// SetErrors sets the errors of the given source, replacing its previous errors. The banner is shown
+// if there are any errors, even if the user dismissed it before.
+// It is safe to call from any goroutine.
+func (b *ErrorBanner) SetErrors(source string, errs []string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if _, ok := b.errors[source]; !ok {
+		b.sources = append(b.sources, source)
+	}
+	b.errors[source] = errs
+
+	var segments []widget.RichTextSegment
+	for _, s := range b.sources {
+		for _, e := range b.errors[s] {
+			segments = append(segments, &widget.TextSegment{
+				Text:  e,
+				Style: widget.RichTextStyle{ColorName: theme.ColorNameError},
+			})
+		}
+	}
+
+	b.text.Segments = segments
+	b.text.Refresh()
+	if len(segments) > 0 {
+		b.Container.Show()
+	} else {
+		b.Container.Hide()
+	}
+}
@@ -1,28 +1,55 @@
 package ui
 
 import (
+	"strings"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 )
 
-// ShowPasswordWindow shows a window for the user to input their password.
-func ShowPasswordWindow(w fyne.Window, label string, onSubmit func(string), onCancel func()) {
+// ShowPasswordWindow shows a window for the user to input their password. onSubmit is called with the password,
+// e.g. to decrypt a secret. If it returns an error, the error is shown in the window and the user can try again.
+// Otherwise the window is hidden and onSuccess is called, so it can type into the window that had focus before.
+func ShowPasswordWindow(w fyne.Window, label string, onSubmit func(string) error, onSuccess func(), onCancel func()) {
 	lbl := widget.NewLabel(label)
+	errText := widget.NewRichText()
+	errText.Wrapping = fyne.TextWrapWord
+	errText.Hide()
 	entry := newTypeablePasswordEntry()
 	entry.onTypedKey = func(key *fyne.KeyEvent) {
 		if key.Name == "Return" {
+			err := onSubmit(entry.Text)
+			if err != nil {
+				errText.Segments = []widget.RichTextSegment{&widget.TextSegment{
+					Text:  capitalize(err.Error()),
+					Style: widget.RichTextStyle{ColorName: theme.ColorNameError},
+				}}
+				errText.Refresh()
+				errText.Show()
+				entry.SetText("")
+				w.Canvas().Focus(entry)
+				return
+			}
 			w.Hide()
-			onSubmit(entry.Text)
+			onSuccess()
 		} else if key.Name == "Escape" {
 			w.Hide()
 			onCancel()
 		}
 	}
 
-	w.SetContent(container.NewVBox(lbl, entry))
+	w.SetContent(container.NewVBox(lbl, entry, errText))
 	w.Resize(fyne.NewSize(300, 80))
 	w.CenterOnScreen()
 	w.Canvas().Focus(entry)
 	w.Show()
 }
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -1,38 +1,40 @@
 package ui
 
 import (
+	"fmt"
 	"math"
 	"strings"
 	"sync"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/sandro-h/snippet/fuzzy"
+	"github.com/sandro-h/snippet/totp"
 	"github.com/sandro-h/snippet/util"
 )
 
-type snippetSegment struct {
-	str         string
-	highlighted bool
-}
-
 type filteredSnippet struct {
 	snippet            *util.Snippet
-	highlightedLabel   []snippetSegment
-	highlightedContent []snippetSegment
+	highlightedLabel   []fuzzy.Segment
+	highlightedContent []fuzzy.Segment
 }
 
 // SearchWidget provides fuzzy search for a list of snippets. The snippets matching the search are displayed in a navigable list.
 type SearchWidget struct {
-	snippets                []*util.Snippet
-	snippetLabels           []string
-	snippetContents         []string
-	filteredSnippets        []*filteredSnippet
-	selectedID              widget.ListItemID
-	onSubmit                func(snippet *util.Snippet)
-	onCancel                func()
+	snippets         []*util.Snippet
+	snippetLabels    []string
+	snippetContents  []string
+	filteredSnippets []*filteredSnippet
+	selectedID       widget.ListItemID
+	// onSubmit is called with slow=true if Shift is held while pressing Return.
+	onSubmit func(snippet *util.Snippet, slow bool)
+	onCancel func()
+	// OnCommand is called when the user submits a search starting with "/", e.g. "/profile work".
+	OnCommand               func(name string, args []string)
 	List                    *widget.List
 	Entry                   *typeableEntry
 	renderLock              sync.Mutex
@@ -40,10 +42,16 @@ type SearchWidget struct {
 	highlightedLabelStyle   widget.RichTextStyle
 	contentStyle            widget.RichTextStyle
 	highlightedContentStyle widget.RichTextStyle
+	// totpRows tells for each row of the list whether it shows a TOTP snippet. The list only has rows for
+	// the visible snippets. It is guarded by renderLock.
+	totpRows      map[fyne.CanvasObject]bool
+	countdownLock sync.Mutex
+	stopCountdown chan struct{}
 }
 
-// NewSearchWidget creates a new SearchWidget.
-func NewSearchWidget(snippets []*util.Snippet, onSubmit func(snippet *util.Snippet), onCancel func()) *SearchWidget {
+// NewSearchWidget creates a new SearchWidget. onSubmit is called with slow=true if the snippet is submitted
+// with Shift+Return, to type it in slow mode.
+func NewSearchWidget(snippets []*util.Snippet, onSubmit func(snippet *util.Snippet, slow bool), onCancel func()) *SearchWidget {
 	w := &SearchWidget{
 		onSubmit: onSubmit,
 		onCancel: onCancel,
@@ -63,14 +71,71 @@ func NewSearchWidget(snippets []*util.Snippet, onSubmit func(snippet *util.Snipp
 		highlightedContentStyle: widget.RichTextStyle{
 			ColorName: theme.ColorNamePrimary,
 			Inline:    true,
-		}}
+		},
+		totpRows: make(map[fyne.CanvasObject]bool),
+	}
 	w.createList()
 	w.createEntry()
 	w.SetSnippets(snippets)
 	return w
 }
 
+// StartCountdowns redraws the list every second while TOTP snippets are visible, to update their countdowns.
+// It should be called when the window is shown.
+func (w *SearchWidget) StartCountdowns() {
+	w.countdownLock.Lock()
+	defer w.countdownLock.Unlock()
+	if w.stopCountdown != nil {
+		return
+	}
+	w.stopCountdown = make(chan struct{})
+	go w.refreshCountdowns(w.stopCountdown)
+}
+
+// StopCountdowns stops redrawing the countdowns. It should be called when the window is hidden.
+func (w *SearchWidget) StopCountdowns() {
+	w.countdownLock.Lock()
+	defer w.countdownLock.Unlock()
+	if w.stopCountdown != nil {
+		close(w.stopCountdown)
+		w.stopCountdown = nil
+	}
+}
+
+func (w *SearchWidget) refreshCountdowns(stop chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if w.showsTOTP() {
+				w.List.Refresh()
+			}
+		}
+	}
+}
+
+// showsTOTP returns whether any visible row of the list shows a TOTP snippet.
+func (w *SearchWidget) showsTOTP() bool {
+	w.renderLock.Lock()
+	defer w.renderLock.Unlock()
+	for _, isTOTP := range w.totpRows {
+		if isTOTP {
+			return true
+		}
+	}
+	return false
+}
+
+// totpCountdown shows how many seconds the current code of a TOTP snippet is still valid.
+func totpCountdown(opts *totp.Options, now time.Time) string {
+	return fmt.Sprintf("  %ds", int(math.Ceil(totp.Remaining(now, opts.Period).Seconds())))
+}
+
 // SetSnippets sets a new list of snippets for the widget to display.
+// It is safe to call from any goroutine.
 func (w *SearchWidget) SetSnippets(snippets []*util.Snippet) {
 	var snippetLabels []string
 	var snippetContents []string
@@ -78,16 +143,33 @@ func (w *SearchWidget) SetSnippets(snippets []*util.Snippet) {
 		snippetLabels = append(snippetLabels, s.Label)
 		snippetContents = append(snippetContents, strings.ReplaceAll(s.Content, "\n", "\\n"))
 	}
+	w.renderLock.Lock()
 	w.snippetLabels = snippetLabels
 	w.snippetContents = snippetContents
 	w.snippets = snippets
+	w.renderLock.Unlock()
 	w.Entry.OnChanged(w.Entry.Text)
 }
 
+func (w *SearchWidget) selectedSnippet() *util.Snippet {
+	w.renderLock.Lock()
+	defer w.renderLock.Unlock()
+	if w.selectedID >= 0 && w.selectedID < len(w.filteredSnippets) {
+		return w.filteredSnippets[w.selectedID].snippet
+	}
+	return nil
+}
+
+func (w *SearchWidget) filteredCount() int {
+	w.renderLock.Lock()
+	defer w.renderLock.Unlock()
+	return len(w.filteredSnippets)
+}
+
 func (w *SearchWidget) createList() {
 	w.List = widget.NewList(
 		func() int {
-			return len(w.filteredSnippets)
+			return w.filteredCount()
 		},
 		func() fyne.CanvasObject {
 			label := widget.NewRichTextWithText("tmpl lbl")
@@ -95,19 +177,26 @@ func (w *SearchWidget) createList() {
 			return container.NewHBox(label, content)
 		},
 		func(id widget.ListItemID, item fyne.CanvasObject) {
-			if id >= len(w.filteredSnippets) {
-				return
-			}
-
 			container := item.(*fyne.Container)
 			label := container.Objects[0].(*widget.RichText)
 			content := container.Objects[1].(*widget.RichText)
 
 			w.renderLock.Lock()
+			if id >= len(w.filteredSnippets) {
+				w.totpRows[item] = false
+				w.renderLock.Unlock()
+				return
+			}
+			snippet := w.filteredSnippets[id].snippet
+			w.totpRows[item] = snippet.TOTP != nil
 			label.Segments = createTextSegments(w.filteredSnippets[id].highlightedLabel, w.labelStyle, w.highlightedLabelStyle)
 			content.Segments = createTextSegments(w.filteredSnippets[id].highlightedContent, w.contentStyle, w.highlightedContentStyle)
 			w.renderLock.Unlock()
 
+			if snippet.TOTP != nil {
+				content.Segments = append(content.Segments, &widget.TextSegment{Text: totpCountdown(snippet.TOTP, time.Now()), Style: w.contentStyle})
+			}
+
 			ellipsis(container, content, w.contentStyle)
 			label.Refresh()
 			content.Refresh()
@@ -120,11 +209,11 @@ func (w *SearchWidget) createList() {
 	w.List.Select(0)
 }
 
-func createTextSegments(segments []snippetSegment, style widget.RichTextStyle, highlightedStyle widget.RichTextStyle) []widget.RichTextSegment {
+func createTextSegments(segments []fuzzy.Segment, style widget.RichTextStyle, highlightedStyle widget.RichTextStyle) []widget.RichTextSegment {
 	var textSegments []widget.RichTextSegment
 	for _, s := range segments {
-		textSegment := widget.TextSegment{Text: s.str}
-		if s.highlighted {
+		textSegment := widget.TextSegment{Text: s.Str}
+		if s.Highlighted {
 			textSegment.Style = highlightedStyle
 		} else {
 			textSegment.Style = style
@@ -139,18 +228,22 @@ func (w *SearchWidget) createEntry() {
 	w.Entry = newTypeableEntry()
 
 	resetSearch := func(retainSelection bool) {
-		selectedLabel := w.filteredSnippets[w.selectedID].snippet.Label
+		selected := w.selectedSnippet()
+		// The window is usually hidden now, so the Shift release would be missed.
+		w.Entry.shiftDown = false
 		w.Entry.Text = ""
 		w.Entry.OnChanged(w.Entry.Text)
 
-		if retainSelection {
+		if retainSelection && selected != nil {
 			newIndex := -1
+			w.renderLock.Lock()
 			for i, s := range w.filteredSnippets {
-				if s.snippet.Label == selectedLabel {
+				if s.snippet.Label == selected.Label {
 					newIndex = i
 					break
 				}
 			}
+			w.renderLock.Unlock()
 			if newIndex > -1 {
 				w.List.Select(newIndex)
 			}
@@ -161,13 +254,19 @@ func (w *SearchWidget) createEntry() {
 	}
 
 	w.Entry.onTypedKey = func(key *fyne.KeyEvent) {
-		if key.Name == "Down" {
-			w.List.Select((w.selectedID + 1) % len(w.filteredSnippets))
-		} else if key.Name == "Up" {
-			w.List.Select((len(w.filteredSnippets) + w.selectedID - 1) % len(w.filteredSnippets))
+		count := w.filteredCount()
+		if key.Name == "Down" && count > 0 {
+			w.List.Select((w.selectedID + 1) % count)
+		} else if key.Name == "Up" && count > 0 {
+			w.List.Select((count + w.selectedID - 1) % count)
 		} else if key.Name == "Return" {
-			if w.selectedID >= 0 && w.selectedID < len(w.filteredSnippets) {
-				w.onSubmit(w.filteredSnippets[w.selectedID].snippet)
+			if strings.HasPrefix(w.Entry.Text, "/") && w.OnCommand != nil {
+				if fields := strings.Fields(w.Entry.Text[1:]); len(fields) > 0 {
+					w.OnCommand(fields[0], fields[1:])
+				}
+				resetSearch(false)
+			} else if selected := w.selectedSnippet(); selected != nil {
+				w.onSubmit(selected, w.Entry.shiftDown)
 				resetSearch(true)
 			}
 		} else if key.Name == "Escape" {
@@ -176,15 +275,19 @@ func (w *SearchWidget) createEntry() {
 		}
 	}
 	w.Entry.OnChanged = func(s string) {
-		matches := fuzzy.SearchFuzzyMulti(s, w.snippetLabels, w.snippetContents)
+		w.renderLock.Lock()
+		snippets, snippetLabels, snippetContents := w.snippets, w.snippetLabels, w.snippetContents
+		w.renderLock.Unlock()
+
+		matches := fuzzy.SearchFuzzyMulti(s, snippetLabels, snippetContents)
 		var filteredSnippets []*filteredSnippet
 
 		for _, m := range matches {
-			highlightedLabel := createHighlightedSegments(w.snippetLabels[m.Index], m.Match1)
-			highlightedContent := createHighlightedSegments(w.snippetContents[m.Index], m.Match2)
+			highlightedLabel := fuzzy.HighlightSegments(snippetLabels[m.Index], m.Match1)
+			highlightedContent := fuzzy.HighlightSegments(snippetContents[m.Index], m.Match2)
 
 			s := &filteredSnippet{
-				snippet:            w.snippets[m.Index],
+				snippet:            snippets[m.Index],
 				highlightedLabel:   highlightedLabel,
 				highlightedContent: highlightedContent,
 			}
@@ -193,6 +296,8 @@ func (w *SearchWidget) createEntry() {
 
 		w.renderLock.Lock()
 		w.filteredSnippets = filteredSnippets
+		// The refresh below updates the rows that are still visible.
+		w.totpRows = make(map[fyne.CanvasObject]bool)
 		w.renderLock.Unlock()
 
 		w.List.Refresh()
@@ -200,32 +305,10 @@ func (w *SearchWidget) createEntry() {
 	}
 }
 
-func createHighlightedSegments(text string, match fuzzy.Match) []snippetSegment {
-	segments := []snippetSegment{{str: text, highlighted: false}}
-	if match.Index > -1 {
-		segments = make([]snippetSegment, 0)
-
-		li := 0
-		for _, r := range match.MatchedRanges {
-			if r.Start >= len(text) {
-				break
-			}
-			if r.Start > li {
-				segments = append(segments, snippetSegment{str: text[li:r.Start], highlighted: false})
-			}
-			segments = append(segments, snippetSegment{str: text[r.Start : r.End+1], highlighted: true})
-			li = r.End + 1
-		}
-		if li < len(text) {
-			segments = append(segments, snippetSegment{str: text[li:], highlighted: false})
-		}
-	}
-	return segments
-}
-
 type typeableEntry struct {
 	widget.Entry
 	onTypedKey func(key *fyne.KeyEvent)
+	shiftDown  bool
 }
 
 func newTypeableEntry() *typeableEntry {
@@ -241,6 +324,7 @@ func newTypeablePasswordEntry() *typeableEntry {
 			Wrapping: fyne.TextTruncate,
 		},
 		nil,
+		false,
 	}
 	e.ExtendBaseWidget(e)
 	return e
@@ -253,6 +337,21 @@ func (e *typeableEntry) TypedKey(key *fyne.KeyEvent) {
 	}
 }
 
+// KeyDown tracks whether Shift is held, since fyne's TypedKey events have no modifiers.
+func (e *typeableEntry) KeyDown(key *fyne.KeyEvent) {
+	if key.Name == desktop.KeyShiftLeft || key.Name == desktop.KeyShiftRight {
+		e.shiftDown = true
+	}
+	e.Entry.KeyDown(key)
+}
+
+func (e *typeableEntry) KeyUp(key *fyne.KeyEvent) {
+	if key.Name == desktop.KeyShiftLeft || key.Name == desktop.KeyShiftRight {
+		e.shiftDown = false
+	}
+	e.Entry.KeyUp(key)
+}
+
 func ellipsis(container *fyne.Container, label *widget.RichText, ellipsisStyle widget.RichTextStyle) {
 	w := measureRichText(label)
 	if label.Position().X+w > container.Size().Width {
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sandro-h/snippet/typing"
+	"gopkg.in/yaml.v2"
+)
+
+// How long to wait for the undo hotkey to be released before undoing anyway.
+const undoReleaseTimeout = 2 * time.Second
+
+// lastInsertFile remembers what the last typed snippet inserted, so it can also be undone with "snippet undo".
+// It contains only the length of the snippet, not its content.
+func lastInsertFile() string {
+	return filepath.Join(files.StateDir, "last_insert")
+}
+
+func saveLastInsert(insert typing.Insert) {
+	bytes, err := yaml.Marshal(insert)
+	if err == nil {
+		err = os.MkdirAll(files.StateDir, 0700)
+	}
+	if err == nil {
+		err = os.WriteFile(lastInsertFile(), bytes, 0600)
+	}
+	if err != nil {
+		log.Printf("Could not remember the typed snippet for undo: %s", err)
+	}
+}
+
+// clearLastInsert forgets the last typed snippet. It is called before typing starts, so a snippet that is aborted
+// or fails partway cannot be mistaken for the previous one by undo, which would remove the wrong amount of text.
+func clearLastInsert() {
+	err := os.Remove(lastInsertFile())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Could not forget the last typed snippet for undo: %s", err)
+	}
+}
+
+// undoLastInsert removes the last typed snippet from the focused app. It can only be undone once.
+func undoLastInsert() error {
+	bytes, err := os.ReadFile(lastInsertFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return errors.New("nothing to undo")
+	} else if err != nil {
+		return err
+	}
+
+	var insert typing.Insert
+	err = yaml.Unmarshal(bytes, &insert)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", lastInsertFile(), err)
+	}
+
+	err = os.Remove(lastInsertFile())
+	if err != nil {
+		return err
+	}
+	return typing.Undo(insert)
+}
+
+func runUndo(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("undo takes no arguments")
+	}
+
+	// Give the launcher that ran the command time to return the focus to the app.
+	time.Sleep(menuTypeDelay)
+	return undoLastInsert()
+}
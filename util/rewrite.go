@@ -0,0 +1,226 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+type replacement struct {
+	start, end int
+	value      string
+}
+
+// RewriteSecrets calls rewrite for the secret or encrypted TOTP seed of every snippet in the snippets file and replaces it
+// with the returned value, keeping the comments and formatting of the file. Returning the secret unchanged
+// leaves it as is. If rewrite fails for a snippet, its secret is kept and the error is returned with the
+// other snippet errors. It returns the number of rewritten secrets.
+func RewriteSecrets(snippetsFile string, rewrite func(label string, secret string) (string, error)) (int, []*SnippetError, error) {
+	bytes, err := os.ReadFile(snippetsFile)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var doc yaml.Node
+	err = yaml.Unmarshal(bytes, &doc)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", snippetsFile, err)
+	}
+	if len(doc.Content) == 0 {
+		return 0, nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return 0, nil, fmt.Errorf("%s:%d: file must be a map of snippet labels to snippets", snippetsFile, root.Line)
+	}
+
+	content := string(bytes)
+	var replacements []replacement
+	var snippetErrs []*SnippetError
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key := root.Content[i]
+		value := root.Content[i+1]
+		secret := mappingValue(value, "secret")
+		if secret == nil {
+			secret = mappingValue(value, "totp")
+		}
+		if secret == nil || secret.Kind != yaml.ScalarNode {
+			continue
+		}
+		newErr := func(err error) *SnippetError {
+			return &SnippetError{File: snippetsFile, Line: secret.Line, Label: key.Value, Err: err}
+		}
+
+		newSecret, err := rewrite(key.Value, secret.Value)
+		if err != nil {
+			snippetErrs = append(snippetErrs, newErr(err))
+			continue
+		}
+		if newSecret == secret.Value {
+			continue
+		}
+
+		r, err := scalarReplacement(content, secret, value.Style&yaml.FlowStyle != 0, newSecret)
+		if err != nil {
+			snippetErrs = append(snippetErrs, newErr(err))
+			continue
+		}
+		replacements = append(replacements, r)
+	}
+
+	if len(replacements) == 0 {
+		return 0, snippetErrs, nil
+	}
+
+	// Replace from the end, so the offsets of the other replacements stay valid.
+	sort.Slice(replacements, func(i, j int) bool { return replacements[i].start > replacements[j].start })
+	for _, r := range replacements {
+		content = content[:r.start] + r.value + content[r.end:]
+	}
+
+	err = writeFileAtomic(snippetsFile, []byte(content))
+	if err != nil {
+		return 0, snippetErrs, err
+	}
+	return len(replacements), snippetErrs, nil
+}
+
+// AddSecret appends a snippet with the encrypted secret to the snippets file, keeping the rest of the file as is.
+// The field is the snippet field of the secret, e.g. secret or secret_age. The label must not exist in the file yet.
+func AddSecret(snippetsFile string, label string, field string, secret string) error {
+	content, err := os.ReadFile(snippetsFile)
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	err = yaml.Unmarshal(content, &doc)
+	if err != nil {
+		return fmt.Errorf("%s: %w", snippetsFile, err)
+	}
+	if len(doc.Content) > 0 {
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode || root.Style&yaml.FlowStyle != 0 {
+			return fmt.Errorf("%s:%d: file must be a block map of snippet labels to snippets", snippetsFile, root.Line)
+		}
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if root.Content[i].Value == label {
+				return fmt.Errorf("%s:%d: snippet %s already exists", snippetsFile, root.Content[i].Line, label)
+			}
+		}
+	}
+
+	snippet := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: label},
+		{Kind: yaml.MappingNode, Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: field},
+			{Kind: yaml.ScalarNode, Value: secret},
+		}},
+	}}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	err = enc.Encode(snippet)
+	if err != nil {
+		return err
+	}
+
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		content = append(content, '\n')
+	}
+	return writeFileAtomic(snippetsFile, append(content, buf.Bytes()...))
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// scalarReplacement finds the scalar's value in the file content by its position. Only single-line
+// scalars are supported. The new value is quoted if the scalar is in a flow mapping, where commas
+// would end the value.
+func scalarReplacement(content string, node *yaml.Node, inFlow bool, newValue string) (replacement, error) {
+	start, ok := offsetOf(content, node.Line, node.Column)
+	if !ok {
+		return replacement{}, fmt.Errorf("cannot find secret in file")
+	}
+
+	quoted := node.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle) != 0
+	if node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return replacement{}, fmt.Errorf("cannot rewrite multi-line secret, put it on a single line")
+	}
+	if quoted {
+		start++
+	} else if inFlow {
+		newValue = `"` + newValue + `"`
+	}
+
+	end := start + len(node.Value)
+	if end > len(content) || content[start:end] != node.Value {
+		return replacement{}, fmt.Errorf("cannot rewrite multi-line secret, put it on a single line")
+	}
+	return replacement{start: start, end: end, value: newValue}, nil
+}
+
+// offsetOf returns the byte offset of the 1-based line and column, where the column counts characters.
+func offsetOf(content string, line int, column int) (int, bool) {
+	offset := 0
+	for l := 1; l < line; l++ {
+		i := strings.IndexByte(content[offset:], '\n')
+		if i < 0 {
+			return 0, false
+		}
+		offset += i + 1
+	}
+	for c := 1; c < column; c++ {
+		if offset >= len(content) {
+			return 0, false
+		}
+		_, size := utf8.DecodeRuneInString(content[offset:])
+		offset += size
+	}
+	return offset, true
+}
+
+// writeFileAtomic replaces the file with a new file, so it is never left half-written. The file mode is kept.
+// If the file is a symlink, e.g. into a dotfiles repository, its target is replaced and the symlink is kept.
+func writeFileAtomic(file string, content []byte) error {
+	if target, err := filepath.EvalSymlinks(file); err == nil {
+		file = target
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Chmod(info.Mode())
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
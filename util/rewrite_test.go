@@ -0,0 +1,160 @@
+package util
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewriteSecrets(t *testing.T) {
+	file := writeSnippetsFile(t, `---
+# My secrets
+foo: bar
+# Passphrase for the keystore
+keystore passphrase:
+  secret: AES256:abc # the old one
+quoted:
+  copy: normal
+  secret: "AES256:def"
+flow: {secret: AES256:ghi}
+"größe ünd":   {secret: 'AES256:jkl'}
+new: {secret: v2:xyz}
+`)
+
+	n, snippetErrs, err := RewriteSecrets(file, func(label string, secret string) (string, error) {
+		if !strings.HasPrefix(secret, "AES256:") {
+			return secret, nil
+		}
+		return "v2:m=1,t=2:" + strings.ToUpper(secret[7:]), nil
+	})
+
+	assert.Nil(t, err)
+	assert.Empty(t, snippetErrs)
+	assert.Equal(t, 4, n)
+	bytes, _ := os.ReadFile(file)
+	assert.Equal(t, `---
+# My secrets
+foo: bar
+# Passphrase for the keystore
+keystore passphrase:
+  secret: v2:m=1,t=2:ABC # the old one
+quoted:
+  copy: normal
+  secret: "v2:m=1,t=2:DEF"
+flow: {secret: "v2:m=1,t=2:GHI"}
+"größe ünd":   {secret: 'v2:m=1,t=2:JKL'}
+new: {secret: v2:xyz}
+`, string(bytes))
+
+	snippets, _, _ := LoadSnippets(file)
+	assert.Equal(t, "v2:m=1,t=2:GHI", snippets[3].Secret)
+}
+
+func TestRewriteSecretsTOTP(t *testing.T) {
+	file := writeSnippetsFile(t, `vpn:
+  totp: AES256:abc
+  totp_digits: 8
+`)
+
+	n, snippetErrs, err := RewriteSecrets(file, func(label string, secret string) (string, error) {
+		return "v2:new", nil
+	})
+
+	assert.Nil(t, err)
+	assert.Empty(t, snippetErrs)
+	assert.Equal(t, 1, n)
+	bytes, _ := os.ReadFile(file)
+	assert.Equal(t, "vpn:\n  totp: v2:new\n  totp_digits: 8\n", string(bytes))
+}
+
+func TestRewriteSecretsReportsFailures(t *testing.T) {
+	file := writeSnippetsFile(t, `a:
+  secret: AES256:abc
+b:
+  secret: AES256:def
+c:
+  secret: >
+    AES256:ghi
+`)
+
+	n, snippetErrs, err := RewriteSecrets(file, func(label string, secret string) (string, error) {
+		if label == "a" {
+			return "", errors.New("wrong password")
+		}
+		return "v2:new", nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Len(t, snippetErrs, 2)
+	assert.Equal(t, file+":2: snippet a: wrong password", snippetErrs[0].Error())
+	assert.Contains(t, snippetErrs[1].Error(), "snippet c: cannot rewrite multi-line secret")
+	bytes, _ := os.ReadFile(file)
+	assert.Contains(t, string(bytes), "a:\n  secret: AES256:abc\nb:\n  secret: v2:new\n")
+}
+
+func TestAddSecret(t *testing.T) {
+	file := writeSnippetsFile(t, "---\n# My snippets\nfoo: bar")
+
+	assert.Nil(t, AddSecret(file, "db password", "secret", "v2:argon2id:m=1,t=2,p=3:abc:def"))
+	assert.Nil(t, AddSecret(file, "team: token", "secret_age", "YWdl"))
+
+	bytes, _ := os.ReadFile(file)
+	assert.Equal(t, `---
+# My snippets
+foo: bar
+db password:
+  secret: v2:argon2id:m=1,t=2,p=3:abc:def
+'team: token':
+  secret_age: YWdl
+`, string(bytes))
+	snippets, snippetErrs, _ := LoadSnippets(file)
+	assert.Empty(t, snippetErrs)
+	assert.Equal(t, "v2:argon2id:m=1,t=2,p=3:abc:def", snippets[1].Secret)
+	assert.Equal(t, "YWdl", snippets[2].SecretAge)
+}
+
+func TestAddSecretSymlink(t *testing.T) {
+	target := writeSnippetsFile(t, "foo: bar\n")
+	link := filepath.Join(t.TempDir(), "snippets.yml")
+	assert.Nil(t, os.Symlink(target, link))
+
+	assert.Nil(t, AddSecret(link, "baz", "secret", "v2:abc"))
+
+	dest, err := os.Readlink(link)
+	assert.Nil(t, err)
+	assert.Equal(t, target, dest)
+	bytes, _ := os.ReadFile(target)
+	assert.Equal(t, "foo: bar\nbaz:\n  secret: v2:abc\n", string(bytes))
+}
+
+func TestAddSecretEmptyFile(t *testing.T) {
+	file := writeSnippetsFile(t, "")
+
+	assert.Nil(t, AddSecret(file, "foo", "secret", "v2:abc"))
+
+	bytes, _ := os.ReadFile(file)
+	assert.Equal(t, "foo:\n  secret: v2:abc\n", string(bytes))
+}
+
+func TestAddSecretExistingLabel(t *testing.T) {
+	file := writeSnippetsFile(t, "foo: bar\nbaz: qux\n")
+
+	err := AddSecret(file, "baz", "secret", "v2:abc")
+
+	assert.EqualError(t, err, file+":2: snippet baz already exists")
+	bytes, _ := os.ReadFile(file)
+	assert.Equal(t, "foo: bar\nbaz: qux\n", string(bytes))
+}
+
+func TestAddSecretFlowFile(t *testing.T) {
+	file := writeSnippetsFile(t, "{foo: bar}\n")
+
+	err := AddSecret(file, "baz", "secret", "v2:abc")
+
+	assert.EqualError(t, err, file+":1: file must be a block map of snippet labels to snippets")
+}
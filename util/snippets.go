@@ -4,11 +4,15 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
-	"gopkg.in/yaml.v2"
+	"github.com/sandro-h/snippet/passgen"
+	"github.com/sandro-h/snippet/secrets"
+	"github.com/sandro-h/snippet/totp"
+	"gopkg.in/yaml.v3"
 )
 
 // CopyMode describes whether a snippet is copy-pasted instead of typed and in what fashion.
@@ -18,21 +22,63 @@ const (
 	// CopyModeNone uses regular typing instead of copy-pasting.
 	CopyModeNone CopyMode = iota
 	// CopyModeNormal uses the standard Ctrl+V shortcut to copy-paste the snippet
-	CopyModeNormal = iota
+	CopyModeNormal
 	// CopyModeShell uses the Ctrl+Shift+V shortcut to copy-paste the snippet into a terminal, where
 	// Ctrl+V usually doesn't work.
-	CopyModeShell = iota
+	CopyModeShell
+	// CopyModeClipboardOnly copies the snippet to the clipboard without pasting it.
+	CopyModeClipboardOnly
+)
+
+// Output describes where a snippet is sent to.
+type Output int
+
+const (
+	// OutputType types or pastes the snippet into the focused window, depending on its CopyMode.
+	OutputType Output = iota
+	// OutputFile appends the snippet to a file.
+	OutputFile
+	// OutputStdout prints the snippet to stdout when it is used from the CLI. The snippet widget types it.
+	OutputStdout
+	// OutputPrimary sets the X11 PRIMARY selection, so the snippet can be pasted with a middle click.
+	OutputPrimary
 )
 
 // Snippet describes a snippet of text.
+// SecretDecrypted and SecretLastUsed are runtime state that is managed by the store package. SecretDecrypted
+// is nil while the secret is locked.
 type Snippet struct {
-	Label           string
-	Content         string
-	Secret          string
-	SecretDecrypted string
+	Label   string
+	Content string
+	Secret  string
+	// SecretAge is a secret encrypted with age for one or more recipients, instead of a password.
+	SecretAge string
+	// SecretTTL overrides the secret_ttl in config.yml for this secret. Zero uses the global TTL.
+	SecretTTL time.Duration
+	// TOTP is set for snippets that type a 2FA code. Their Secret is the encrypted seed.
+	TOTP            *totp.Options
+	SecretDecrypted *secrets.Buffer
 	SecretLastUsed  time.Time
 	Args            []SnippetArg
 	Copy            CopyMode
+	Output          Output
+	// OutputFile is the file that OutputFile appends to. If empty, the output_file in config.yml is used.
+	OutputFile string
+	Typing     TypingOptions
+}
+
+// IsSecret returns whether the snippet content is encrypted, either with a password or with age.
+func (s *Snippet) IsSecret() bool {
+	return s.Secret != "" || s.SecretAge != ""
+}
+
+// TypingOptions override the global typing settings for a snippet. Nil fields use the global setting.
+type TypingOptions struct {
+	// TypingDelay is the delay between typing chunks of ChunkSize characters.
+	TypingDelay *time.Duration
+	// LineDelay is the delay before typing the next line.
+	LineDelay *time.Duration
+	ChunkSize *int
 }
 
 // SnippetArg defines an argument to be replaced in the snippet.
@@ -43,7 +89,7 @@ type SnippetArg struct {
 
 // ArgResolver resolves the argument so it can be replaced in the snippet.
 type ArgResolver interface {
-	Resolve() string
+	Resolve() (string, error)
 }
 
 // ManualResolver marks arguments that require user input.
@@ -52,8 +98,8 @@ type ManualResolver struct{}
 
 // Resolve resolves the input argument. In this case it's a NOOP since the UI code handles
 // manual args.
-func (m *ManualResolver) Resolve() string {
-	return ""
+func (m *ManualResolver) Resolve() (string, error) {
+	return "", nil
 }
 
 // RandomNumberResolver resolves the argument to a random integer number.
@@ -63,11 +109,11 @@ type RandomNumberResolver struct {
 }
 
 // Resolve returns a random integer number within the resolver range [min,max).
-func (m *RandomNumberResolver) Resolve() string {
+func (m *RandomNumberResolver) Resolve() (string, error) {
 	s := rand.NewSource(time.Now().UnixNano())
 	r := rand.New(s)
 	num := m.min + r.Intn(m.max-m.min)
-	return strconv.Itoa(num)
+	return strconv.Itoa(num), nil
 }
 
 // NowResolver resolves the argument to the current date and time.
@@ -77,59 +123,150 @@ type NowResolver struct {
 
 // Resolve returns the current date and time, formatted according to the resolver's
 // format string.
-func (m *NowResolver) Resolve() string {
-	return time.Now().Format(m.format)
+func (m *NowResolver) Resolve() (string, error) {
+	return time.Now().Format(m.format), nil
 }
 
-// LoadSnippets loads a list of snippets from a YAML file.
-func LoadSnippets(snippetsFile string) ([]*Snippet, error) {
-	bytes, err := os.ReadFile(snippetsFile)
+// PasswordResolver resolves the argument to a new random password.
+type PasswordResolver struct {
+	opts passgen.Options
+}
+
+// Resolve returns a new random password. The options are validated when the snippet is loaded,
+// so it only fails if the system's random number generator fails.
+func (m *PasswordResolver) Resolve() (string, error) {
+	pwd, err := passgen.Password(m.opts)
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("could not generate password: %w", err)
 	}
+	// The arguments of a snippet are strings, so the generated password cannot be wiped once it is resolved.
+	defer secrets.Wipe(pwd)
+	return string(pwd), nil
+}
+
+// PassphraseResolver resolves the argument to a new passphrase of random words.
+type PassphraseResolver struct {
+	opts passgen.PassphraseOptions
+}
 
-	var rawSnippets map[string]interface{}
-	err = yaml.Unmarshal(bytes, &rawSnippets)
+// Resolve returns a new passphrase of random words. Like PasswordResolver, it only fails if the system's
+// random number generator fails.
+func (m *PassphraseResolver) Resolve() (string, error) {
+	phrase, err := passgen.Passphrase(m.opts)
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("could not generate passphrase: %w", err)
 	}
+	defer secrets.Wipe(phrase)
+	return string(phrase), nil
+}
 
+// SnippetError describes a problem with a snippet in a snippets file.
+type SnippetError struct {
+	File  string
+	Line  int
+	Label string
+	Err   error
+}
+
+func (e *SnippetError) Error() string {
+	return fmt.Sprintf("%s:%d: snippet %s: %s", e.File, e.Line, e.Label, e.Err)
+}
+
+func (e *SnippetError) Unwrap() error {
+	return e.Err
+}
+
+// LoadSnippets loads a list of snippets from a YAML file, in the order they appear in the file.
+// Invalid snippets are skipped and returned as SnippetErrors. Snippets with minor problems (e.g. an invalid
+// optional field) are loaded, but their problems are returned as SnippetErrors too.
+// An error is only returned if the file as a whole cannot be loaded.
+func LoadSnippets(snippetsFile string) ([]*Snippet, []*SnippetError, error) {
+	return LoadSnippetFiles([]string{snippetsFile})
+}
+
+// LoadSnippetFiles loads the snippets of several files like LoadSnippets, one file after the other.
+// A label can only be used once across all files; later snippets with the same label are skipped.
+// An error is returned if any of the files cannot be loaded.
+func LoadSnippetFiles(snippetsFiles []string) ([]*Snippet, []*SnippetError, error) {
 	var snippets []*Snippet
-	for k, v := range rawSnippets {
-		snippet, err := unmarshalSnippet(k, v)
+	var snippetErrs []*SnippetError
+	labels := make(map[string]string)
+	for _, f := range snippetsFiles {
+		fileSnippets, fileErrs, err := loadSnippets(f, labels)
 		if err != nil {
-			fmt.Println(err)
-		} else {
-			snippets = append(snippets, snippet)
+			return nil, nil, err
 		}
+		snippets = append(snippets, fileSnippets...)
+		snippetErrs = append(snippetErrs, fileErrs...)
 	}
-	return snippets, nil
+	return snippets, snippetErrs, nil
 }
 
-// ReloadSnippets reloads the snippets (usually when snippetsFile content changed),
-// and transfers any runtime data of the old snippets to the matching new snippets.
-func ReloadSnippets(snippetsFile string, oldSnippets []*Snippet) ([]*Snippet, error) {
-	newSnippets, err := LoadSnippets(snippetsFile)
+// loadSnippets loads the snippets of a single file. labels holds the files of the already loaded labels
+// and is updated with the file's labels.
+func loadSnippets(snippetsFile string, labels map[string]string) ([]*Snippet, []*SnippetError, error) {
+	bytes, err := os.ReadFile(snippetsFile)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// Transfer runtime snippet data to newly loaded snippets.
-	oldSnippetsMap := make(map[string]*Snippet)
-	for _, s := range oldSnippets {
-		oldSnippetsMap[s.Label] = s
+	var doc yaml.Node
+	err = yaml.Unmarshal(bytes, &doc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", snippetsFile, err)
 	}
 
-	for _, s := range newSnippets {
-		if os, ok := oldSnippetsMap[s.Label]; ok {
-			s.SecretDecrypted = os.SecretDecrypted
-			s.SecretLastUsed = os.SecretLastUsed
+	// Empty file
+	if len(doc.Content) == 0 {
+		return nil, nil, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("%s:%d: file must be a map of snippet labels to snippets", snippetsFile, root.Line)
+	}
+
+	var snippets []*Snippet
+	var snippetErrs []*SnippetError
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key := root.Content[i]
+		newErr := func(err error) *SnippetError {
+			return &SnippetError{File: snippetsFile, Line: key.Line, Label: key.Value, Err: err}
+		}
+
+		if f, ok := labels[key.Value]; ok {
+			if f == snippetsFile {
+				snippetErrs = append(snippetErrs, newErr(fmt.Errorf("duplicate label")))
+			} else {
+				snippetErrs = append(snippetErrs, newErr(fmt.Errorf("duplicate label, already defined in %s", f)))
+			}
+			continue
+		}
+		labels[key.Value] = snippetsFile
+
+		var rawSnippet interface{}
+		err := root.Content[i+1].Decode(&rawSnippet)
+		if err != nil {
+			snippetErrs = append(snippetErrs, newErr(err))
+			continue
+		}
+
+		var warnings []error
+		snippet, err := unmarshalSnippet(key.Value, rawSnippet, filepath.Dir(snippetsFile), &warnings)
+		for _, w := range warnings {
+			snippetErrs = append(snippetErrs, newErr(w))
+		}
+		if err != nil {
+			snippetErrs = append(snippetErrs, newErr(err))
+		} else {
+			snippets = append(snippets, snippet)
 		}
 	}
-	return newSnippets, nil
+	return snippets, snippetErrs, nil
 }
 
-func unmarshalSnippet(key string, rawSnippet interface{}) (*Snippet, error) {
+// unmarshalSnippet parses a snippet. Relative paths in it are relative to dir, the directory of its snippets file.
+func unmarshalSnippet(key string, rawSnippet interface{}, dir string, warnings *[]error) (*Snippet, error) {
 	snippet := &Snippet{
 		Label: key,
 	}
@@ -137,39 +274,60 @@ func unmarshalSnippet(key string, rawSnippet interface{}) (*Snippet, error) {
 	switch rv := rawSnippet.(type) {
 	case string:
 		snippet.Content = rv
-	case map[interface{}]interface{}:
-		err := unmarshalContent(key, rv, snippet)
+	case map[string]interface{}:
+		err := unmarshalContent(rv, snippet, warnings)
 		if err != nil {
 			return nil, err
 		}
-		err = unmarshalArguments(key, rv, snippet)
+		err = unmarshalArguments(rv, snippet, dir)
 		if err != nil {
 			return nil, err
 		}
+		unmarshalOutput(rv, snippet, warnings)
+		unmarshalTypingOptions(rv, snippet, warnings)
+		unmarshalSecretTTL(rv, snippet, warnings)
 	default:
-		return nil, fmt.Errorf("error loading snippet %s: unknown type %T", key, rawSnippet)
+		return nil, fmt.Errorf("unknown type %T", rawSnippet)
 	}
 
 	return snippet, nil
 }
 
-func unmarshalContent(key string, rawValue map[interface{}]interface{}, snippet *Snippet) error {
+func unmarshalContent(rawValue map[string]interface{}, snippet *Snippet, warnings *[]error) error {
 	var ok bool
 	content, hasContent := rawValue["content"]
 	secret, hasSecret := rawValue["secret"]
+	secretAge, hasSecretAge := rawValue["secret_age"]
+	seed, hasTOTP := rawValue["totp"]
 	if hasContent {
 		snippet.Content, ok = content.(string)
 		if !ok {
-			return fmt.Errorf("error loading snippet %s: 'content' field is not string", key)
+			return fmt.Errorf("'content' field is not string")
 		}
 	} else if hasSecret {
 		snippet.Content = "******"
 		snippet.Secret, ok = secret.(string)
 		if !ok {
-			return fmt.Errorf("error loading snippet %s: 'secret' field is not string", key)
+			return fmt.Errorf("'secret' field is not string")
+		}
+	} else if hasSecretAge {
+		snippet.Content = "******"
+		snippet.SecretAge, ok = secretAge.(string)
+		if !ok {
+			return fmt.Errorf("'secret_age' field is not string")
+		}
+	} else if hasTOTP {
+		snippet.Content = "******"
+		snippet.Secret, ok = seed.(string)
+		if !ok {
+			return fmt.Errorf("'totp' field is not string")
+		}
+		err := unmarshalTOTP(rawValue, snippet)
+		if err != nil {
+			return err
 		}
 	} else {
-		return fmt.Errorf("error loading snippet %s: missing 'content' or 'secret' field", key)
+		return fmt.Errorf("missing 'content', 'secret', 'secret_age' or 'totp' field")
 	}
 
 	copy, hasCopy := rawValue["copy"]
@@ -186,6 +344,9 @@ func unmarshalContent(key string, rawValue map[interface{}]interface{}, snippet
 			case "shell":
 				snippet.Copy = CopyModeShell
 				break
+			case "clipboard-only":
+				snippet.Copy = CopyModeClipboardOnly
+				break
 			default:
 				snippet.Copy = CopyModeNone
 				ok = false
@@ -193,43 +354,146 @@ func unmarshalContent(key string, rawValue map[interface{}]interface{}, snippet
 		}
 
 		if !ok {
-			fmt.Printf("Warning: snippet %s - 'copy' field should be one of: none, normal, shell. Ignoring field.\n", key)
+			*warnings = append(*warnings, fmt.Errorf("'copy' field should be one of: none, normal, shell, clipboard-only. Ignoring field"))
 		}
 	}
 
 	return nil
 }
 
-func unmarshalArguments(key string, rawValue map[interface{}]interface{}, snippet *Snippet) error {
+var outputNames = map[string]Output{
+	"type":    OutputType,
+	"file":    OutputFile,
+	"stdout":  OutputStdout,
+	"primary": OutputPrimary,
+}
+
+func unmarshalOutput(rawValue map[string]interface{}, snippet *Snippet, warnings *[]error) {
+	if raw, ok := rawValue["output"]; ok {
+		str, _ := raw.(string)
+		output, ok := outputNames[str]
+		if ok {
+			snippet.Output = output
+		} else {
+			*warnings = append(*warnings, fmt.Errorf("'output' field should be one of: type, file, stdout, primary. Ignoring field"))
+		}
+	}
+
+	if raw, ok := rawValue["output_file"]; ok {
+		str, ok := raw.(string)
+		if ok && str != "" {
+			snippet.OutputFile = str
+		} else {
+			*warnings = append(*warnings, fmt.Errorf("'output_file' field should be a file path. Ignoring field"))
+		}
+	}
+}
+
+func unmarshalTypingOptions(rawValue map[string]interface{}, snippet *Snippet, warnings *[]error) {
+	for _, field := range []struct {
+		name string
+		dest **time.Duration
+	}{{"typing_delay", &snippet.Typing.TypingDelay}, {"line_delay", &snippet.Typing.LineDelay}} {
+		raw, ok := rawValue[field.name]
+		if !ok {
+			continue
+		}
+		str, ok := raw.(string)
+		dur, err := time.ParseDuration(str)
+		if !ok || err != nil || dur < 0 {
+			*warnings = append(*warnings, fmt.Errorf("'%s' field should be a duration like 20ms. Ignoring field", field.name))
+			continue
+		}
+		*field.dest = &dur
+	}
+
+	if raw, ok := rawValue["chunk_size"]; ok {
+		size, ok := raw.(int)
+		if !ok || size < 1 {
+			*warnings = append(*warnings, fmt.Errorf("'chunk_size' field should be a positive number. Ignoring field"))
+		} else {
+			snippet.Typing.ChunkSize = &size
+		}
+	}
+}
+
+func unmarshalTOTP(rawValue map[string]interface{}, snippet *Snippet) error {
+	opts := totp.DefaultOptions
+	if raw, ok := rawValue["totp_digits"]; ok {
+		if opts.Digits, ok = raw.(int); !ok {
+			return fmt.Errorf("'totp_digits' field is not a number")
+		}
+	}
+	if raw, ok := rawValue["totp_period"]; ok {
+		seconds, ok := raw.(int)
+		if !ok {
+			return fmt.Errorf("'totp_period' field is not a number of seconds")
+		}
+		opts.Period = time.Duration(seconds) * time.Second
+	}
+	if raw, ok := rawValue["totp_algorithm"]; ok {
+		name, _ := raw.(string)
+		alg, err := totp.ParseAlgorithm(name)
+		if err != nil {
+			return fmt.Errorf("'totp_algorithm' - %w", err)
+		}
+		opts.Algorithm = alg
+	}
+	if err := opts.Validate(); err != nil {
+		return fmt.Errorf("'totp' - %w", err)
+	}
+	snippet.TOTP = &opts
+	return nil
+}
+
+func unmarshalSecretTTL(rawValue map[string]interface{}, snippet *Snippet, warnings *[]error) {
+	raw, ok := rawValue["secret_ttl"]
+	if !ok {
+		return
+	}
+	str, ok := raw.(string)
+	dur, err := time.ParseDuration(str)
+	if !ok || err != nil || dur <= 0 {
+		*warnings = append(*warnings, fmt.Errorf("'secret_ttl' field should be a positive duration like 1m. Ignoring field"))
+		return
+	}
+	snippet.SecretTTL = dur
+}
+
+func unmarshalArguments(rawValue map[string]interface{}, snippet *Snippet, dir string) error {
 	args, ok := rawValue["args"]
 	if ok {
 		rawArgList, ok := args.([]interface{})
 		if !ok {
-			return fmt.Errorf("error loading snippet %s: 'args' field is not a list of strings", key)
+			return fmt.Errorf("'args' field is not a list of strings")
 		}
 		for i, a := range rawArgList {
 			switch arg := a.(type) {
 			case string:
 				snippet.Args = append(snippet.Args, SnippetArg{Name: arg, Resolver: &ManualResolver{}})
-			case map[interface{}]interface{}:
-				parsedArg, err := unmarshalComplexArg(arg)
+			case map[string]interface{}:
+				parsedArg, err := unmarshalComplexArg(arg, dir)
 				if err != nil {
-					return fmt.Errorf("error loading snippet %s: 'args[%d]' - %s", key, i, err.Error())
+					return fmt.Errorf("'args[%d]' - %s", i, err.Error())
 				}
 				snippet.Args = append(snippet.Args, *parsedArg)
 			default:
-				return fmt.Errorf("error loading snippet %s: 'args[%d]' field is not string or map", key, i)
+				return fmt.Errorf("'args[%d]' field is not string or map", i)
 			}
 		}
 	}
 	return nil
 }
 
-func unmarshalComplexArg(rawArg map[interface{}]interface{}) (*SnippetArg, error) {
-	name, ok := rawArg["name"]
+func unmarshalComplexArg(rawArg map[string]interface{}, dir string) (*SnippetArg, error) {
+	rawName, ok := rawArg["name"]
 	if !ok {
 		return nil, fmt.Errorf("arg is missing 'name' field")
 	}
+	name, ok := rawName.(string)
+	if !ok {
+		return nil, fmt.Errorf("'name' field is not a string")
+	}
 
 	argType, ok := rawArg["type"]
 	if !ok {
@@ -245,6 +509,10 @@ func unmarshalComplexArg(rawArg map[interface{}]interface{}) (*SnippetArg, error
 		resolver, err = unmarshalRandomNumberResolver(rawArg)
 	case "now":
 		resolver, err = unmarshalNowResolver(rawArg)
+	case "password":
+		resolver, err = unmarshalPasswordResolver(rawArg)
+	case "passphrase":
+		resolver, err = unmarshalPassphraseResolver(rawArg, dir)
 	default:
 		return nil, fmt.Errorf("unknown type '%s'", argType)
 	}
@@ -253,10 +521,10 @@ func unmarshalComplexArg(rawArg map[interface{}]interface{}) (*SnippetArg, error
 		return nil, err
 	}
 
-	return &SnippetArg{Name: name.(string), Resolver: resolver}, nil
+	return &SnippetArg{Name: name, Resolver: resolver}, nil
 }
 
-func unmarshalRandomNumberResolver(rawArg map[interface{}]interface{}) (*RandomNumberResolver, error) {
+func unmarshalRandomNumberResolver(rawArg map[string]interface{}) (*RandomNumberResolver, error) {
 	min := 0
 	max := 100
 
@@ -279,7 +547,7 @@ func unmarshalRandomNumberResolver(rawArg map[interface{}]interface{}) (*RandomN
 	return &RandomNumberResolver{min, max}, nil
 }
 
-func unmarshalNowResolver(rawArg map[interface{}]interface{}) (*NowResolver, error) {
+func unmarshalNowResolver(rawArg map[string]interface{}) (*NowResolver, error) {
 	format := "2006-01-02 15:04:05"
 	formatVal, ok := rawArg["format"]
 	if ok {
@@ -292,6 +560,124 @@ func unmarshalNowResolver(rawArg map[interface{}]interface{}) (*NowResolver, err
 	return &NowResolver{format}, nil
 }
 
+func unmarshalPasswordResolver(rawArg map[string]interface{}) (*PasswordResolver, error) {
+	opts := passgen.DefaultOptions
+	err := unmarshalIntField(rawArg, "length", &opts.Length)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range []struct {
+		name  string
+		value *bool
+	}{
+		{"lower", &opts.Lower},
+		{"upper", &opts.Upper},
+		{"digits", &opts.Digits},
+		{"symbols", &opts.Symbols},
+		{"exclude_ambiguous", &opts.ExcludeAmbiguous},
+	} {
+		err = unmarshalBoolField(rawArg, f.name, f.value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = opts.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return &PasswordResolver{opts}, nil
+}
+
+func unmarshalPassphraseResolver(rawArg map[string]interface{}, dir string) (*PassphraseResolver, error) {
+	opts := passgen.DefaultPassphraseOptions
+	err := unmarshalIntField(rawArg, "words", &opts.Words)
+	if err != nil {
+		return nil, err
+	}
+
+	separatorVal, ok := rawArg["separator"]
+	if ok {
+		opts.Separator, ok = separatorVal.(string)
+		if !ok {
+			return nil, fmt.Errorf("'separator' field is not a string")
+		}
+	}
+
+	wordListVal, ok := rawArg["wordlist"]
+	if ok {
+		wordListFile, ok := wordListVal.(string)
+		if !ok {
+			return nil, fmt.Errorf("'wordlist' field is not a string")
+		}
+		wordListFile, err := ResolvePath(wordListFile, dir)
+		if err != nil {
+			return nil, fmt.Errorf("'wordlist' - %s", err)
+		}
+		content, err := os.ReadFile(wordListFile)
+		if err != nil {
+			return nil, fmt.Errorf("'wordlist' - %s", err)
+		}
+		opts.WordList = passgen.ParseWordList(content)
+	}
+
+	err = opts.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return &PassphraseResolver{opts}, nil
+}
+
+func unmarshalIntField(rawArg map[string]interface{}, name string, value *int) error {
+	rawVal, ok := rawArg[name]
+	if !ok {
+		return nil
+	}
+	*value, ok = rawVal.(int)
+	if !ok {
+		return fmt.Errorf("'%s' field is not an integer", name)
+	}
+	return nil
+}
+
+func unmarshalBoolField(rawArg map[string]interface{}, name string, value *bool) error {
+	rawVal, ok := rawArg[name]
+	if !ok {
+		return nil
+	}
+	*value, ok = rawVal.(bool)
+	if !ok {
+		return fmt.Errorf("'%s' field is not a boolean", name)
+	}
+	return nil
+}
+
+// ResolveArgs resolves all automatic arguments of the snippet. It returns the resolved values
+// and the names of the manual arguments, which have to be filled out by the user.
+// Manual arguments with the same name as one of the variables are resolved to the variable's value.
+// If an automatic argument cannot be resolved, the snippet must not be output, so the error is returned.
+func ResolveArgs(snippet *Snippet, variables map[string]string) (map[string]string, []string, error) {
+	var manualArgs []string
+	vals := make(map[string]string)
+	for _, arg := range snippet.Args {
+		switch arg.Resolver.(type) {
+		case *ManualResolver:
+			if v, ok := variables[arg.Name]; ok {
+				vals[arg.Name] = v
+			} else {
+				manualArgs = append(manualArgs, arg.Name)
+			}
+		default:
+			val, err := arg.Resolver.Resolve()
+			if err != nil {
+				return nil, nil, fmt.Errorf("argument %s: %w", arg.Name, err)
+			}
+			vals[arg.Name] = val
+		}
+	}
+	return vals, manualArgs, nil
+}
+
 // InstantiateArgs takes a snippet content and a map of argument names to values and replaces
 // all instances of {arg} with the corresponding value in the map.
 func InstantiateArgs(content string, vals map[string]string) string {
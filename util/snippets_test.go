@@ -0,0 +1,339 @@
+package util
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sandro-h/snippet/totp"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeSnippetsFile(t *testing.T, content string) string {
+	file := filepath.Join(t.TempDir(), "snippets.yml")
+	assert.Nil(t, os.WriteFile(file, []byte(content), 0644))
+	return file
+}
+
+func TestLoadSnippets(t *testing.T) {
+	file := writeSnippetsFile(t, `---
+foo: bar
+docker bash:
+  content: docker exec -ti {container} bash
+  args: [container]
+keystore passphrase:
+  secret: AES256:abc
+team token:
+  secret_age: YWdlLWVuY3J5cHRpb24ub3JnL3YxCg==
+my script:
+  copy: shell
+  content: echo hi
+`)
+
+	snippets, snippetErrs, err := LoadSnippets(file)
+
+	assert.Nil(t, err)
+	assert.Empty(t, snippetErrs)
+	assert.Len(t, snippets, 5)
+	assert.Equal(t, &Snippet{Label: "foo", Content: "bar"}, snippets[0])
+	assert.Equal(t, "docker bash", snippets[1].Label)
+	assert.Equal(t, []SnippetArg{{Name: "container", Resolver: &ManualResolver{}}}, snippets[1].Args)
+	assert.Equal(t, "AES256:abc", snippets[2].Secret)
+	assert.True(t, snippets[2].IsSecret())
+	assert.Equal(t, "YWdlLWVuY3J5cHRpb24ub3JnL3YxCg==", snippets[3].SecretAge)
+	assert.Equal(t, "******", snippets[3].Content)
+	assert.True(t, snippets[3].IsSecret())
+	assert.Equal(t, CopyModeShell, snippets[4].Copy)
+	assert.False(t, snippets[4].IsSecret())
+}
+
+func TestLoadSnippetsReportsInvalidSnippets(t *testing.T) {
+	file := writeSnippetsFile(t, `foo: bar
+no content:
+  copy: normal
+bad arg:
+  content: "{x}"
+  args:
+    - name: x
+      type: unknown
+foo: duplicate
+bad copy:
+  content: baz
+  copy: sometimes
+`)
+
+	snippets, snippetErrs, err := LoadSnippets(file)
+
+	assert.Nil(t, err)
+	var labels []string
+	for _, s := range snippets {
+		labels = append(labels, s.Label)
+	}
+	assert.Equal(t, []string{"foo", "bad copy"}, labels)
+
+	var errs []string
+	for _, e := range snippetErrs {
+		errs = append(errs, e.Error())
+	}
+	assert.Equal(t, []string{
+		file + ":2: snippet no content: missing 'content', 'secret', 'secret_age' or 'totp' field",
+		file + ":4: snippet bad arg: 'args[0]' - unknown type 'unknown'",
+		file + ":9: snippet foo: duplicate label",
+		file + ":10: snippet bad copy: 'copy' field should be one of: none, normal, shell, clipboard-only. Ignoring field",
+	}, errs)
+}
+
+func TestLoadSnippetsOutput(t *testing.T) {
+	file := writeSnippetsFile(t, `copied:
+  content: foo
+  copy: clipboard-only
+logged:
+  content: bar
+  output: file
+  output_file: ~/notes.txt
+selected:
+  content: baz
+  output: primary
+bad output:
+  content: qux
+  output: printer
+`)
+
+	snippets, snippetErrs, err := LoadSnippets(file)
+
+	assert.Nil(t, err)
+	assert.Len(t, snippets, 4)
+	assert.Equal(t, CopyModeClipboardOnly, snippets[0].Copy)
+	assert.Equal(t, OutputType, snippets[0].Output)
+	assert.Equal(t, OutputFile, snippets[1].Output)
+	assert.Equal(t, "~/notes.txt", snippets[1].OutputFile)
+	assert.Equal(t, OutputPrimary, snippets[2].Output)
+	assert.Equal(t, OutputType, snippets[3].Output)
+	assert.Len(t, snippetErrs, 1)
+	assert.Contains(t, snippetErrs[0].Error(), "'output' field should be one of: type, file, stdout, primary")
+}
+
+func TestLoadSnippetsTypingOptions(t *testing.T) {
+	file := writeSnippetsFile(t, `remote:
+  content: ls -la
+  typing_delay: 30ms
+  chunk_size: 4
+slow lines:
+  content: foo
+  line_delay: 1s
+  typing_delay: fast
+`)
+
+	snippets, snippetErrs, err := LoadSnippets(file)
+
+	assert.Nil(t, err)
+	assert.Len(t, snippets, 2)
+	assert.Equal(t, 30*time.Millisecond, *snippets[0].Typing.TypingDelay)
+	assert.Equal(t, 4, *snippets[0].Typing.ChunkSize)
+	assert.Nil(t, snippets[0].Typing.LineDelay)
+	assert.Equal(t, time.Second, *snippets[1].Typing.LineDelay)
+	assert.Nil(t, snippets[1].Typing.TypingDelay)
+	assert.Len(t, snippetErrs, 1)
+	assert.Contains(t, snippetErrs[0].Error(), "'typing_delay' field should be a duration")
+}
+
+func TestLoadSnippetsSecretTTL(t *testing.T) {
+	file := writeSnippetsFile(t, `root password:
+  secret: v2:abc
+  secret_ttl: 30s
+bad ttl:
+  secret: v2:def
+  secret_ttl: -1m
+`)
+
+	snippets, snippetErrs, err := LoadSnippets(file)
+
+	assert.Nil(t, err)
+	assert.Len(t, snippets, 2)
+	assert.Equal(t, 30*time.Second, snippets[0].SecretTTL)
+	assert.Equal(t, time.Duration(0), snippets[1].SecretTTL)
+	assert.Len(t, snippetErrs, 1)
+	assert.Contains(t, snippetErrs[0].Error(), "'secret_ttl' field should be a positive duration")
+}
+
+func TestLoadSnippetsTOTP(t *testing.T) {
+	file := writeSnippetsFile(t, `vpn:
+  totp: v2:abc
+sso:
+  totp: MASTER:v2:aes-gcm:def
+  totp_digits: 8
+  totp_period: 60
+  totp_algorithm: sha256
+bad digits:
+  totp: v2:ghi
+  totp_digits: 4
+bad algorithm:
+  totp: v2:jkl
+  totp_algorithm: md5
+`)
+
+	snippets, snippetErrs, err := LoadSnippets(file)
+
+	assert.Nil(t, err)
+	assert.Len(t, snippets, 2)
+	assert.Equal(t, "v2:abc", snippets[0].Secret)
+	assert.Equal(t, "******", snippets[0].Content)
+	assert.Equal(t, &totp.DefaultOptions, snippets[0].TOTP)
+	assert.Equal(t, "MASTER:v2:aes-gcm:def", snippets[1].Secret)
+	assert.Equal(t, &totp.Options{Digits: 8, Period: time.Minute, Algorithm: totp.SHA256}, snippets[1].TOTP)
+	assert.Len(t, snippetErrs, 2)
+	assert.Contains(t, snippetErrs[0].Error(), "'totp' - digits must be between 6 and 8, but was 4")
+	assert.Contains(t, snippetErrs[1].Error(), "'totp_algorithm' - unknown algorithm md5")
+}
+
+func TestLoadSnippetsPasswordArgs(t *testing.T) {
+	dir := t.TempDir()
+	wordList := filepath.Join(dir, "words.txt")
+	assert.Nil(t, os.WriteFile(wordList, []byte("11111\tcorrect\n11112\thorse\n"), 0644))
+	file := writeSnippetsFile(t, `---
+new user:
+  content: "{pwd} {phrase} {custom} {relative}"
+  args:
+    - name: pwd
+      type: password
+      length: 12
+      symbols: false
+      exclude_ambiguous: true
+    - name: phrase
+      type: passphrase
+      words: 3
+      separator: " "
+    - name: custom
+      type: passphrase
+      wordlist: `+wordList+`
+    - name: relative
+      type: passphrase
+      words: 2
+      wordlist: words/relative.txt
+bad length:
+  content: "{pwd}"
+  args:
+    - name: pwd
+      type: password
+      length: 2
+bad wordlist:
+  content: "{pwd}"
+  args:
+    - name: pwd
+      type: passphrase
+      wordlist: `+filepath.Join(dir, "missing.txt")+`
+`)
+
+	assert.Nil(t, os.Mkdir(filepath.Join(filepath.Dir(file), "words"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(filepath.Dir(file), "words", "relative.txt"), []byte("battery\nstaple\n"), 0644))
+
+	snippets, snippetErrs, err := LoadSnippets(file)
+
+	assert.Nil(t, err)
+	assert.Len(t, snippets, 1)
+	vals, manualArgs, err := ResolveArgs(snippets[0], nil)
+	assert.Nil(t, err)
+	assert.Empty(t, manualArgs)
+	assert.Regexp(t, `^[a-zA-Z0-9]{12}$`, vals["pwd"])
+	assert.NotContains(t, vals["pwd"], "l")
+	assert.Regexp(t, `^[a-z]+ [a-z]+ [a-z]+$`, vals["phrase"])
+	assert.Regexp(t, `^((correct|horse)-){5}(correct|horse)$`, vals["custom"])
+	assert.Regexp(t, `^(battery|staple)-(battery|staple)$`, vals["relative"])
+
+	assert.Len(t, snippetErrs, 2)
+	assert.EqualError(t, snippetErrs[0].Err, "'args[0]' - length must be at least 4, but was 2")
+	assert.Contains(t, snippetErrs[1].Err.Error(), "'args[0]' - 'wordlist' - ")
+}
+
+func TestLoadSnippetsInvalidFile(t *testing.T) {
+	file := writeSnippetsFile(t, "foo: [bar\n")
+
+	_, _, err := LoadSnippets(file)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), file+": yaml: ")
+}
+
+func TestLoadSnippetsNotAMap(t *testing.T) {
+	file := writeSnippetsFile(t, "- foo\n- bar\n")
+
+	_, _, err := LoadSnippets(file)
+
+	assert.EqualError(t, err, file+":1: file must be a map of snippet labels to snippets")
+}
+
+func TestLoadSnippetsEmptyFile(t *testing.T) {
+	file := writeSnippetsFile(t, "")
+
+	snippets, snippetErrs, err := LoadSnippets(file)
+
+	assert.Nil(t, err)
+	assert.Empty(t, snippetErrs)
+	assert.Empty(t, snippets)
+}
+
+func TestLoadSnippetFiles(t *testing.T) {
+	file1 := writeSnippetsFile(t, "foo: bar\nshared: from file1\n")
+	file2 := writeSnippetsFile(t, "baz: qux\nshared: from file2\n")
+
+	snippets, snippetErrs, err := LoadSnippetFiles([]string{file1, file2})
+
+	assert.Nil(t, err)
+	var contents []string
+	for _, s := range snippets {
+		contents = append(contents, s.Content)
+	}
+	assert.Equal(t, []string{"bar", "from file1", "qux"}, contents)
+	assert.Len(t, snippetErrs, 1)
+	assert.Equal(t, file2+":2: snippet shared: duplicate label, already defined in "+file1, snippetErrs[0].Error())
+}
+
+func TestLoadSnippetFilesInvalidFile(t *testing.T) {
+	file1 := writeSnippetsFile(t, "foo: bar\n")
+	file2 := writeSnippetsFile(t, "foo: [bar\n")
+
+	snippets, _, err := LoadSnippetFiles([]string{file1, file2})
+
+	assert.Error(t, err)
+	assert.Nil(t, snippets)
+}
+
+func TestResolveArgsWithVariables(t *testing.T) {
+	snippet := &Snippet{
+		Label:   "ssh",
+		Content: "ssh {user}@{host}",
+		Args: []SnippetArg{
+			{Name: "user", Resolver: &ManualResolver{}},
+			{Name: "host", Resolver: &ManualResolver{}},
+		},
+	}
+
+	vals, manualArgs, err := ResolveArgs(snippet, map[string]string{"host": "jump.customer.example", "other": "x"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"host": "jump.customer.example"}, vals)
+	assert.Equal(t, []string{"user"}, manualArgs)
+}
+
+type failingResolver struct{}
+
+func (r *failingResolver) Resolve() (string, error) {
+	return "", errors.New("no entropy")
+}
+
+func TestResolveArgsError(t *testing.T) {
+	snippet := &Snippet{
+		Label:   "new user",
+		Content: "{user} {pwd}",
+		Args: []SnippetArg{
+			{Name: "user", Resolver: &ManualResolver{}},
+			{Name: "pwd", Resolver: &failingResolver{}},
+		},
+	}
+
+	_, _, err := ResolveArgs(snippet, nil)
+
+	assert.EqualError(t, err, "argument pwd: no entropy")
+}
@@ -1,6 +1,8 @@
 package util
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 )
@@ -49,3 +51,17 @@ func MaxDur(d1 time.Duration, d2 time.Duration) time.Duration {
 	}
 	return d2
 }
+
+// ResolvePath expands a leading ~/ to the home directory and makes relative paths relative to dir.
+func ResolvePath(f string, dir string) (string, error) {
+	if strings.HasPrefix(f, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, f[2:]), nil
+	} else if !filepath.IsAbs(f) {
+		return filepath.Join(dir, f), nil
+	}
+	return f, nil
+}
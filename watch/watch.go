@@ -0,0 +1,158 @@
+package watch
+
+import (
+	"log"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const relevantOps = fsnotify.Create | fsnotify.Write | fsnotify.Rename | fsnotify.Remove
+
+// Watcher watches files for changes.
+// It watches the files' parent directories instead of the files themselves, so it keeps working when
+// editors save by writing a new file and renaming it over the old one.
+type Watcher struct {
+	fsWatcher *fsnotify.Watcher
+	lock      sync.Mutex // Guards files and dirs, which can change while watching.
+	files     map[string]string
+	dirs      map[string]bool
+	debounce  time.Duration
+	onChange  func(changed []string)
+	done      chan struct{}
+}
+
+// Watch starts watching the files. Changes are coalesced until no further change happened for the debounce
+// duration, then onChange is called once with the changed files. The files are passed to onChange
+// exactly as given to Watch.
+// The files do not need to exist yet, but their parent directories do.
+func Watch(files []string, debounce time.Duration, onChange func(changed []string)) (*Watcher, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		fsWatcher: fsWatcher,
+		dirs:      make(map[string]bool),
+		debounce:  debounce,
+		onChange:  onChange,
+		done:      make(chan struct{}),
+	}
+
+	err = w.SetFiles(files)
+	if err != nil {
+		fsWatcher.Close()
+		return nil, err
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// SetFiles replaces the watched files. If the new files cannot be watched, an error is returned
+// and the previous files are still watched.
+func (w *Watcher) SetFiles(files []string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	newFiles := make(map[string]string)
+	for _, f := range files {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			return err
+		}
+		newFiles[abs] = f
+	}
+
+	// The new directories are added in sorted order, so which ones are rolled back on a failure is predictable.
+	seen := make(map[string]bool)
+	var newDirs []string
+	for abs := range newFiles {
+		d := filepath.Dir(abs)
+		if !w.dirs[d] && !seen[d] {
+			seen[d] = true
+			newDirs = append(newDirs, d)
+		}
+	}
+	sort.Strings(newDirs)
+
+	for i, d := range newDirs {
+		err := w.fsWatcher.Add(d)
+		if err != nil {
+			// Stop watching the directories that were added for the new files, so only the previous ones are watched.
+			for _, added := range newDirs[:i] {
+				w.fsWatcher.Remove(added)
+				delete(w.dirs, added)
+			}
+			return err
+		}
+		w.dirs[d] = true
+	}
+
+	w.files = newFiles
+	return nil
+}
+
+func (w *Watcher) watchedFile(name string) (string, bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	f, ok := w.files[filepath.Clean(name)]
+	return f, ok
+}
+
+// Close stops watching. Pending changes are discarded.
+func (w *Watcher) Close() error {
+	err := w.fsWatcher.Close()
+	<-w.done
+	return err
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+
+	pending := make(map[string]bool)
+	var timer *time.Timer
+	var fire <-chan time.Time
+
+	for {
+		select {
+		case event, ok := <-w.fsWatcher.Events:
+			if !ok {
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			}
+			f, watched := w.watchedFile(event.Name)
+			if !watched || event.Op&relevantOps == 0 {
+				continue
+			}
+
+			pending[f] = true
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.NewTimer(w.debounce)
+			fire = timer.C
+		case <-fire:
+			var changed []string
+			for f := range pending {
+				changed = append(changed, f)
+			}
+			sort.Strings(changed)
+			pending = make(map[string]bool)
+			fire = nil
+
+			w.onChange(changed)
+		case err, ok := <-w.fsWatcher.Errors:
+			if !ok {
+				return
+			}
+			log.Println("error watching files:", err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testDebounce = 50 * time.Millisecond
+
+type recorder struct {
+	lock    sync.Mutex
+	changes [][]string
+}
+
+func (r *recorder) onChange(changed []string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.changes = append(r.changes, changed)
+}
+
+func (r *recorder) get() [][]string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.changes
+}
+
+func setup(t *testing.T) (string, string, *recorder, *Watcher) {
+	dir := t.TempDir()
+	snippetsFile := filepath.Join(dir, "snippets.yml")
+	configFile := filepath.Join(dir, "config.yml")
+	assert.Nil(t, os.WriteFile(snippetsFile, []byte("foo: bar\n"), 0644))
+
+	r := &recorder{}
+	w, err := Watch([]string{snippetsFile, configFile}, testDebounce, r.onChange)
+	assert.Nil(t, err)
+	return snippetsFile, configFile, r, w
+}
+
+func waitForChanges(r *recorder, count int) [][]string {
+	deadline := time.Now().Add(2 * time.Second)
+	for len(r.get()) < count && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Give the watcher the chance to report unexpected additional changes.
+	time.Sleep(3 * testDebounce)
+	return r.get()
+}
+
+func TestWrite(t *testing.T) {
+	snippetsFile, _, r, w := setup(t)
+	defer w.Close()
+
+	assert.Nil(t, os.WriteFile(snippetsFile, []byte("foo: baz\n"), 0644))
+
+	assert.Equal(t, [][]string{{snippetsFile}}, waitForChanges(r, 1))
+}
+
+func TestRenameOnSave(t *testing.T) {
+	snippetsFile, _, r, w := setup(t)
+	defer w.Close()
+
+	for i := 0; i < 2; i++ {
+		tmp := snippetsFile + ".tmp"
+		assert.Nil(t, os.WriteFile(tmp, []byte("foo: baz\n"), 0644))
+		assert.Nil(t, os.Rename(tmp, snippetsFile))
+		waitForChanges(r, i+1)
+	}
+
+	assert.Equal(t, [][]string{{snippetsFile}, {snippetsFile}}, r.get())
+}
+
+func TestDebounce(t *testing.T) {
+	snippetsFile, configFile, r, w := setup(t)
+	defer w.Close()
+
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, os.WriteFile(snippetsFile, []byte("foo: baz\n"), 0644))
+		time.Sleep(testDebounce / 5)
+	}
+	assert.Nil(t, os.WriteFile(configFile, []byte("secret_ttl: 1m\n"), 0644))
+
+	assert.Equal(t, [][]string{{configFile, snippetsFile}}, waitForChanges(r, 1))
+}
+
+func TestIgnoresOtherFiles(t *testing.T) {
+	snippetsFile, _, r, w := setup(t)
+	defer w.Close()
+
+	assert.Nil(t, os.WriteFile(filepath.Join(filepath.Dir(snippetsFile), "other.yml"), []byte("foo"), 0644))
+
+	assert.Empty(t, waitForChanges(r, 1))
+}
+
+func TestRemove(t *testing.T) {
+	snippetsFile, _, r, w := setup(t)
+	defer w.Close()
+
+	assert.Nil(t, os.Remove(snippetsFile))
+
+	assert.Equal(t, [][]string{{snippetsFile}}, waitForChanges(r, 1))
+}
+
+func TestSetFiles(t *testing.T) {
+	snippetsFile, configFile, r, w := setup(t)
+	defer w.Close()
+
+	otherFile := filepath.Join(t.TempDir(), "other.yml")
+	assert.Nil(t, w.SetFiles([]string{configFile, otherFile}))
+
+	assert.Nil(t, os.WriteFile(snippetsFile, []byte("foo: baz\n"), 0644))
+	assert.Nil(t, os.WriteFile(otherFile, []byte("foo: baz\n"), 0644))
+
+	assert.Equal(t, [][]string{{otherFile}}, waitForChanges(r, 1))
+}
+
+func TestSetFilesMissingDir(t *testing.T) {
+	snippetsFile, _, r, w := setup(t)
+	defer w.Close()
+
+	err := w.SetFiles([]string{filepath.Join(t.TempDir(), "missing", "other.yml")})
+	assert.Error(t, err)
+
+	assert.Nil(t, os.WriteFile(snippetsFile, []byte("foo: baz\n"), 0644))
+	assert.Equal(t, [][]string{{snippetsFile}}, waitForChanges(r, 1))
+}
+
+func TestSetFilesRollsBackOnError(t *testing.T) {
+	snippetsFile, configFile, r, w := setup(t)
+	defer w.Close()
+
+	// The new directories are added in order, so a is added before b fails.
+	base := t.TempDir()
+	assert.Nil(t, os.Mkdir(filepath.Join(base, "a"), 0755))
+	added := filepath.Join(base, "a", "other.yml")
+	err := w.SetFiles([]string{snippetsFile, configFile, added, filepath.Join(base, "b", "other.yml")})
+	assert.Error(t, err)
+
+	assert.Equal(t, map[string]bool{filepath.Dir(snippetsFile): true}, w.dirs)
+	assert.Nil(t, os.WriteFile(added, []byte("foo: baz\n"), 0644))
+	assert.Nil(t, os.WriteFile(snippetsFile, []byte("foo: baz\n"), 0644))
+	assert.Equal(t, [][]string{{snippetsFile}}, waitForChanges(r, 1))
+}